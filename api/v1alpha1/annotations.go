@@ -0,0 +1,24 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+const (
+	// ReconcileRequestAnnotation is set to the current time to request an
+	// immediate reconciliation of an Environment or Promotion,
+	// e.g. by the webhook receiver when a repository was pushed to.
+	ReconcileRequestAnnotation string = "promotions.gitopsprom.io/reconcile-requested-at"
+)
@@ -18,6 +18,18 @@ package v1alpha1
 
 const (
 	ReadyCondition string = "Ready"
+
+	// PullRequestClosedCondition is 'True' while the last pull request of a
+	// Promotion was closed without being merged.
+	PullRequestClosedCondition string = "PullRequestClosed"
+
+	// ApprovedCondition is 'True' if the commit of the source environment
+	// being promoted by a Promotion with an approval policy was approved.
+	ApprovedCondition string = "Approved"
+
+	// BlockedCondition is 'True' while the schedule of a Promotion,
+	// or a FreezeWindow, blocks changes from being promoted.
+	BlockedCondition string = "Blocked"
 )
 
 // Reasons are provided as utility, and not part of the declarative API.
@@ -27,4 +39,27 @@ const (
 
 	// ProgressingReason signals that the operation is in progress.
 	ProgressingReason string = "Progressing"
+
+	// ClosedWithoutMergeReason signals that a pull request was closed without being merged.
+	ClosedWithoutMergeReason string = "ClosedWithoutMerge"
+
+	// HostKeyVerificationFailedReason signals that the host key of the SSH
+	// server of a repository could not be verified.
+	HostKeyVerificationFailedReason string = "HostKeyVerificationFailed"
+
+	// InvalidURLReason signals that the URL of the repository of an
+	// environment is not allowed.
+	InvalidURLReason string = "InvalidURL"
+
+	// WaitingForApprovalReason signals that a promotion is held until the
+	// commit of the source environment being promoted is approved.
+	WaitingForApprovalReason string = "WaitingForApproval"
+
+	// ApprovedReason signals that the commit of the source environment
+	// being promoted was approved.
+	ApprovedReason string = "Approved"
+
+	// BlockedByScheduleReason signals that changes are not promoted, as the
+	// schedule of a promotion, or a FreezeWindow, blocks them.
+	BlockedByScheduleReason string = "BlockedBySchedule"
 )
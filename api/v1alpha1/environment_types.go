@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"time"
+
 	corev1 "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -27,6 +29,11 @@ import (
 
 const (
 	GitProviderGitHub string = "github"
+	GitProviderGitLab string = "gitlab"
+	GitProviderGitea  string = "gitea"
+	// GitProviderForgejo is handled by the Gitea provider,
+	// as Forgejo is API compatible with Gitea.
+	GitProviderForgejo string = "forgejo"
 )
 
 // EnvironmentSpec defines the desired state of Environment
@@ -49,9 +56,52 @@ type EnvironmentSpec struct {
 
 	// GitProvider is the name of the git provider.
 	// Required for pull request strategy.
-	// +Kubebuilder:Validation:Enum=github
+	// +kubebuilder:validation:Enum=github;gitlab;gitea;forgejo
+	// +optional
+	GitProvider string `json:"gitProvider,omitempty"`
+
+	// GitProviderBaseURL is the base URL of a self-hosted GitLab, Gitea or
+	// Forgejo instance, e.g. "https://gitlab.example.com".
+	// Defaults to "https://gitlab.com" for GitLab, and to the scheme and host
+	// of the source URL for Gitea and Forgejo.
+	// +optional
+	GitProviderBaseURL string `json:"gitProviderBaseUrl,omitempty"`
+
+	// Interval at which the source repository is checked for new commits.
+	// Defaults to DefaultEnvironmentInterval.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// CommitAuthor is the author and committer of the commits promotions
+	// create in this environment.
+	// Defaults to DefaultCommitAuthorName and DefaultCommitAuthorEmail.
+	// +optional
+	CommitAuthor *CommitAuthor `json:"commitAuthor,omitempty"`
+
+	// SigningKeySecretRef refers to a secret containing the key the commits
+	// promotions create in this environment are signed with.
+	// The key "signingKey" holds an armored OpenPGP private key, or a PEM
+	// encoded SSH private key, and the optional key "passphrase" its passphrase.
 	// +optional
-	GitProvider string `json:"gitProvider"`
+	SigningKeySecretRef *corev1.LocalObjectReference `json:"signingKeySecretRef,omitempty"`
+}
+
+const (
+	DefaultEnvironmentInterval time.Duration = 5 * time.Minute
+
+	DefaultCommitAuthorName  string = "Promotion Bot"
+	DefaultCommitAuthorEmail string = "bot@promotions.gitopsprom.io"
+)
+
+// CommitAuthor is the identity of a git commit.
+type CommitAuthor struct {
+	// Name of the author.
+	// +required
+	Name string `json:"name"`
+
+	// Email of the author.
+	// +required
+	Email string `json:"email"`
 }
 
 // const (
@@ -80,10 +130,26 @@ const (
 )
 
 // GitRepositoryRef specifies the Git reference to resolve and checkout.
+// Tag, SemVer and Commit only apply to the Environment as source of a
+// Promotion, as target the Environment is always pushed to Branch.
 type GitRepositoryRef struct {
 	// Branch to check out, defaults to 'master' if no other field is defined.
 	// +optional
 	Branch string `json:"branch,omitempty"`
+
+	// Tag to check out, takes precedence over Branch.
+	// +optional
+	Tag string `json:"tag,omitempty"`
+
+	// SemVer is a range of semantic versions, e.g. ">=1.2.0 <2.0.0".
+	// The highest tag in the range is checked out, takes precedence over Tag.
+	// +optional
+	SemVer string `json:"semver,omitempty"`
+
+	// Commit SHA to check out, takes precedence over all other fields.
+	// The commit must be reachable from Branch.
+	// +optional
+	Commit string `json:"commit,omitempty"`
 }
 
 // EnvironmentStatus defines the observed state of Environment
@@ -101,6 +167,16 @@ type EnvironmentStatus struct {
 	// object.
 	// +optional
 	ObservedCommitHash string `json:"observedCommitHash,omitempty"`
+
+	// ObservedRef is the git reference ObservedCommitHash was resolved from,
+	// e.g. "refs/tags/v1.2.0" for a SemVer range.
+	// +optional
+	ObservedRef string `json:"observedRef,omitempty"`
+
+	// LastCheckedTime is the last time the source repository was checked
+	// for new commits.
+	// +optional
+	LastCheckedTime *metav1.Time `json:"lastCheckedTime,omitempty"`
 }
 
 const (
@@ -192,13 +268,24 @@ func (e *Environment) GetStatusConditions() *[]metav1.Condition {
 // 	return e.Name + SSHSecretObjectNameSuffix
 // }
 
+// GetBranch returns the branch of the source repository,
+// or DefaultBranch if none is specified.
 func (e *Environment) GetBranch() string {
-	if e.Spec.Source.Reference != nil {
+	if e.Spec.Source.Reference != nil && e.Spec.Source.Reference.Branch != "" {
 		return e.Spec.Source.Reference.Branch
 	}
 	return DefaultBranch
 }
 
+// GetInterval returns the interval at which the source repository is
+// checked for new commits, or DefaultEnvironmentInterval if none is specified.
+func (e *Environment) GetInterval() time.Duration {
+	if e.Spec.Interval != nil && e.Spec.Interval.Duration > 0 {
+		return e.Spec.Interval.Duration
+	}
+	return DefaultEnvironmentInterval
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 
@@ -0,0 +1,99 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// FreezeWindowSpec defines the desired state of FreezeWindow
+type FreezeWindowSpec struct {
+	// Windows are the recurring windows during which no changes are promoted.
+	// +required
+	// +kubebuilder:validation:MinItems=1
+	Windows []ScheduleWindow `json:"windows"`
+
+	// TimeZone is the IANA time zone of the windows, e.g. "Europe/Vienna".
+	// Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Selector selects the frozen Promotions by their labels.
+	// All Promotions are frozen if not set.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Namespaces restricts the freeze to the Promotions in these namespaces.
+	// Promotions in all namespaces are frozen if empty.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Reason explains the freeze in the status of the blocked Promotions.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// Selects returns true if the Promotion is frozen by the FreezeWindow.
+func (in *FreezeWindow) Selects(promotion *Promotion) (bool, error) {
+	if len(in.Spec.Namespaces) > 0 {
+		found := false
+		for _, namespace := range in.Spec.Namespaces {
+			if namespace == promotion.Namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	if in.Spec.Selector == nil {
+		return true, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(in.Spec.Selector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(promotion.Labels)), nil
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// FreezeWindow is the Schema for the freezewindows API.
+// It blocks the Promotions it selects from promoting changes during its windows,
+// e.g. during a change freeze.
+type FreezeWindow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec FreezeWindowSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// FreezeWindowList contains a list of FreezeWindow
+type FreezeWindowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FreezeWindow `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&FreezeWindow{}, &FreezeWindowList{})
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+
 	corev1 "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -40,11 +42,180 @@ type PromotionSpec struct {
 	Copy []CopyOperation `json:"copy"`
 
 	// Strategy defines the strategy to use when promoting.
+	// "pull-request" opens a pull request to the target environment,
+	// "push" commits directly to the branch of the target environment.
 	// +required
-	// +kubebuilder:validation:Enum=pull-request
+	// +kubebuilder:validation:Enum=pull-request;push
 	Strategy string `json:"strategy"`
+
+	// Suspend tells the controller to suspend the promotion.
+	// Promotions owned by a PromotionPipeline are suspended until
+	// the previous stage of the pipeline is in sync.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// OnSourceChange defines what happens to an open pull request when the
+	// source environment moves on, or the Promotion is edited.
+	// "update" pushes the new changes to the open pull request,
+	// "recreate" closes it and opens a new pull request.
+	// Only used by the "pull-request" strategy.
+	// +optional
+	// +kubebuilder:default=update
+	// +kubebuilder:validation:Enum=update;recreate
+	OnSourceChange string `json:"onSourceChange,omitempty"`
+
+	// ReopenAfter is the cooldown after which a new pull request is opened
+	// if the last one was closed without being merged. If not set, a new pull
+	// request is only opened once the source environment moves on, or the
+	// Promotion is edited.
+	// +optional
+	ReopenAfter *metav1.Duration `json:"reopenAfter,omitempty"`
+
+	// DeletionPolicy defines what happens to the open pull request of the
+	// promotion when the Promotion is deleted.
+	// "orphan" leaves the pull request and its branch behind,
+	// "close" closes the pull request with a comment and deletes its branch.
+	// +optional
+	// +kubebuilder:default=orphan
+	// +kubebuilder:validation:Enum=orphan;close
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// CommitAuthor is the author and committer of the commits of the promotion.
+	// Overrides the commit author of the target environment.
+	// +optional
+	CommitAuthor *CommitAuthor `json:"commitAuthor,omitempty"`
+
+	// SigningKeySecretRef refers to a secret containing the key the commits
+	// of the promotion are signed with, like the one of an Environment.
+	// Overrides the signing key of the target environment.
+	// +optional
+	SigningKeySecretRef *corev1.LocalObjectReference `json:"signingKeySecretRef,omitempty"`
+
+	// CoAuthoredBy adds a "Co-authored-by" trailer for each author of the
+	// promoted commits of the source environment to the commit messages.
+	// +optional
+	CoAuthoredBy bool `json:"coAuthoredBy,omitempty"`
+
+	// Templates overrides the templates of the commit messages and pull requests.
+	// +optional
+	Templates *PromotionTemplates `json:"templates,omitempty"`
+
+	// Approval holds the promotion until a PromotionApproval approves the
+	// commit of the source environment being promoted. Nothing is pushed,
+	// and no pull request is opened or updated, without an approval.
+	// +optional
+	Approval *ApprovalPolicy `json:"approval,omitempty"`
+
+	// Schedule restricts when changes are promoted, e.g. to business hours.
+	// Nothing is pushed, and no pull request is opened or updated, while
+	// the schedule, or a FreezeWindow selecting the Promotion, blocks it.
+	// +optional
+	Schedule *PromotionSchedule `json:"schedule,omitempty"`
+}
+
+// PromotionSchedule defines the windows during which changes are promoted.
+type PromotionSchedule struct {
+	// TimeZone is the IANA time zone of the windows, e.g. "Europe/Vienna".
+	// Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Allow are the windows during which changes are promoted.
+	// Changes are promoted at any time outside of the deny windows if empty.
+	// +optional
+	Allow []ScheduleWindow `json:"allow,omitempty"`
+
+	// Deny are the windows during which no changes are promoted.
+	// They take precedence over the allow windows.
+	// +optional
+	Deny []ScheduleWindow `json:"deny,omitempty"`
+}
+
+// ScheduleWindow is a recurring window, which opens at the times of a cron
+// expression and stays open for a duration.
+type ScheduleWindow struct {
+	// Cron is the cron expression of the times the window opens, with the
+	// fields "minute hour day-of-month month day-of-week", e.g. "0 14 * * FRI",
+	// or a descriptor like "@weekly".
+	// +required
+	Cron string `json:"cron"`
+
+	// Duration is how long the window stays open, e.g. "10h".
+	// +required
+	Duration metav1.Duration `json:"duration"`
+
+	// TimeZone overrides the time zone of the window.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// ApprovalPolicy defines who may approve a promotion.
+type ApprovalPolicy struct {
+	// Approvers are the names of the users whose approvals are accepted.
+	// If empty, the approvals of all users allowed to create
+	// PromotionApprovals in the namespace are accepted.
+	// +optional
+	Approvers []string `json:"approvers,omitempty"`
+}
+
+// Accepts returns true if the approvals of the user are accepted.
+func (in *ApprovalPolicy) Accepts(user string) bool {
+	if user == "" {
+		return false
+	}
+	if len(in.Approvers) == 0 {
+		return true
+	}
+	for _, approver := range in.Approvers {
+		if approver == user {
+			return true
+		}
+	}
+	return false
+}
+
+// PromotionTemplates are Go templates of the messages written by a promotion.
+// Empty templates default to the built-in ones.
+type PromotionTemplates struct {
+	// CommitMessage is the template of the message of the commit
+	// created for each copy operation.
+	// +optional
+	CommitMessage string `json:"commitMessage,omitempty"`
+
+	// PRTitle is the template of the title of pull requests.
+	// +optional
+	PRTitle string `json:"prTitle,omitempty"`
+
+	// PRBody is the template of the description of pull requests.
+	// +optional
+	PRBody string `json:"prBody,omitempty"`
 }
 
+// PromotionFinalizer is the finalizer the controller adds to Promotions,
+// to clean up their pull requests according to the deletion policy.
+const PromotionFinalizer = "promotions.gitopsprom.io/finalizer"
+
+const (
+	PromotionStrategyPullRequest string = "pull-request"
+	PromotionStrategyPush        string = "push"
+)
+
+const (
+	OnSourceChangeUpdate   string = "update"
+	OnSourceChangeRecreate string = "recreate"
+)
+
+const (
+	DeletionPolicyOrphan string = "orphan"
+	DeletionPolicyClose  string = "close"
+)
+
+const (
+	PullRequestStateOpen   string = "open"
+	PullRequestStateMerged string = "merged"
+	PullRequestStateClosed string = "closed"
+)
+
 // CopyOperation defines a file/directory copy operation.
 type CopyOperation struct {
 	// Name is the name you want to give this copy operation.
@@ -52,6 +223,19 @@ type CopyOperation struct {
 	// +required
 	Name string `json:"name"`
 
+	// Type is the type of the copy operation.
+	// "copy" copies the source file or directory to the target path,
+	// "yamlPath" copies the value at YAMLPath in the source file
+	// to TargetYAMLPath in the target file,
+	// "kustomizeImages" merges the images of the source kustomization
+	// into the target kustomization,
+	// "helmValues" merges the Keys of the source Helm values file
+	// into the target Helm values file.
+	// +optional
+	// +kubebuilder:default=copy
+	// +kubebuilder:validation:Enum=copy;yamlPath;kustomizeImages;helmValues
+	Type string `json:"type,omitempty"`
+
 	// The source path to copy from.
 	// +required
 	Source string `json:"source"`
@@ -59,6 +243,75 @@ type CopyOperation struct {
 	// The target path to copy to.
 	// +required
 	Target string `json:"target"`
+
+	// YAMLPath is the path of the value to copy from the source file,
+	// e.g. "spec.template.spec.containers[0].image".
+	// Required if Type is "yamlPath".
+	// +optional
+	YAMLPath string `json:"yamlPath,omitempty"`
+
+	// TargetYAMLPath is the path to copy the value to in the target file.
+	// Missing keys are added. Defaults to YAMLPath.
+	// +optional
+	TargetYAMLPath string `json:"targetYamlPath,omitempty"`
+
+	// Images are the names of the images to merge if Type is "kustomizeImages".
+	// All images of the source kustomization are merged if empty.
+	// Source and Target may refer to the kustomization file or its directory.
+	// +optional
+	Images []string `json:"images,omitempty"`
+
+	// Keys are the dotted paths of the values to merge if Type is "helmValues",
+	// e.g. "image.tag" or "app.config.featureFlags".
+	// Source and Target may refer to the values file or its directory.
+	// +optional
+	Keys []string `json:"keys,omitempty"`
+
+	// Include are glob patterns of the files to copy if Type is "copy" and
+	// Source is a directory, relative to the source directory,
+	// e.g. "manifests/**/*.yaml". All files are copied if empty.
+	// +optional
+	Include []string `json:"include,omitempty"`
+
+	// Exclude are glob patterns of the files not to copy if Type is "copy" and
+	// Source is a directory, relative to the source directory,
+	// e.g. "**/secrets-*.yaml". Exclude takes precedence over Include.
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+
+	// Prune makes the target directory mirror the source directory
+	// if Type is "copy", by deleting the files in the target directory
+	// which do not exist in the source directory.
+	// +optional
+	Prune bool `json:"prune,omitempty"`
+
+	// Protect are glob patterns of files which are never deleted by Prune,
+	// relative to the target directory, e.g. "secrets/**" or "**/*.local.yaml".
+	// +optional
+	Protect []string `json:"protect,omitempty"`
+}
+
+const (
+	CopyOperationTypeCopy            string = "copy"
+	CopyOperationTypeYAMLPath        string = "yamlPath"
+	CopyOperationTypeKustomizeImages string = "kustomizeImages"
+	CopyOperationTypeHelmValues      string = "helmValues"
+)
+
+// GetType returns the type of the copy operation, which defaults to "copy".
+func (in *CopyOperation) GetType() string {
+	if in.Type == "" {
+		return CopyOperationTypeCopy
+	}
+	return in.Type
+}
+
+// GetTargetYAMLPath returns TargetYAMLPath, which defaults to YAMLPath.
+func (in *CopyOperation) GetTargetYAMLPath() string {
+	if in.TargetYAMLPath == "" {
+		return in.YAMLPath
+	}
+	return in.TargetYAMLPath
 }
 
 // PromotionStatus defines the observed state of Promotion
@@ -79,6 +332,56 @@ type PromotionStatus struct {
 	// LastPullRequestNumber is the number of the pull request created by the promotion.
 	// +optional
 	LastPullRequestNumber int `json:"lastPullRequestNumber,omitempty"`
+
+	// LastPullRequestState is the state of the pull request created by the
+	// promotion, one of "open", "merged" or "closed".
+	// +optional
+	LastPullRequestState string `json:"lastPullRequestState,omitempty"`
+
+	// LastPullRequestSourceCommitHash is the commit hash of the source
+	// environment last promoted by the pull request.
+	// +optional
+	LastPullRequestSourceCommitHash string `json:"lastPullRequestSourceCommitHash,omitempty"`
+
+	// LastPullRequestGeneration is the generation of the Promotion
+	// last promoted by the pull request.
+	// +optional
+	LastPullRequestGeneration int64 `json:"lastPullRequestGeneration,omitempty"`
+
+	// ObservedSourceCommitHash is the commit hash of the source environment
+	// observed during the last reconciliation.
+	// +optional
+	ObservedSourceCommitHash string `json:"observedSourceCommitHash,omitempty"`
+
+	// LastSyncedSourceCommitHash is the last commit hash of the source environment
+	// whose changes are known to be contained in the target environment.
+	// +optional
+	LastSyncedSourceCommitHash string `json:"lastSyncedSourceCommitHash,omitempty"`
+
+	// LastSyncedTargetCommitHash is the commit hash of the target environment
+	// at the time it was last known to be in sync with the source environment.
+	// +optional
+	LastSyncedTargetCommitHash string `json:"lastSyncedTargetCommitHash,omitempty"`
+
+	// LastPromotedSourceCommitHash is the last commit hash of the source environment
+	// which has been successfully promoted, i.e. pushed to the target environment,
+	// or contained in a merged pull request.
+	// +optional
+	LastPromotedSourceCommitHash string `json:"lastPromotedSourceCommitHash,omitempty"`
+
+	// ApprovedSourceCommitHash is the commit hash of the source environment
+	// approved by the last accepted PromotionApproval.
+	// +optional
+	ApprovedSourceCommitHash string `json:"approvedSourceCommitHash,omitempty"`
+
+	// ApprovedBy is the user who approved ApprovedSourceCommitHash.
+	// +optional
+	ApprovedBy string `json:"approvedBy,omitempty"`
+
+	// BlockedUntil is the next time changes are promoted, while the schedule
+	// of the Promotion, or a FreezeWindow, blocks it.
+	// +optional
+	BlockedUntil *metav1.Time `json:"blockedUntil,omitempty"`
 }
 
 const (
@@ -131,6 +434,88 @@ func PromotionNotReady(promotion Promotion, reason string, message string) Promo
 	return promotion
 }
 
+// PromotionPullRequestClosed sets the PullRequestClosedCondition to 'True', with
+// the ClosedWithoutMergeReason and the given message. It returns the modified Promotion.
+func PromotionPullRequestClosed(promotion Promotion, message string) Promotion {
+	newCondition := metav1.Condition{
+		Type:    PullRequestClosedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  ClosedWithoutMergeReason,
+		Message: message,
+	}
+	meta.SetStatusCondition(promotion.GetStatusConditions(), newCondition)
+	return promotion
+}
+
+// PromotionWaitingForApproval sets the ApprovedCondition and the ReadyCondition
+// to 'False', with the WaitingForApprovalReason and the given message.
+// It returns the modified Promotion.
+func PromotionWaitingForApproval(promotion Promotion, message string) Promotion {
+	for _, conditionType := range []string{ApprovedCondition, ReadyCondition} {
+		meta.SetStatusCondition(promotion.GetStatusConditions(), metav1.Condition{
+			Type:    conditionType,
+			Status:  metav1.ConditionFalse,
+			Reason:  WaitingForApprovalReason,
+			Message: message,
+		})
+	}
+	return promotion
+}
+
+// PromotionApproved records that the source environment at sourceCommit was
+// approved by approvedBy, and sets the ApprovedCondition to 'True'.
+// It returns the modified Promotion.
+func PromotionApproved(promotion Promotion, sourceCommit string, approvedBy string) Promotion {
+	promotion.Status.ApprovedSourceCommitHash = sourceCommit
+	promotion.Status.ApprovedBy = approvedBy
+	meta.SetStatusCondition(promotion.GetStatusConditions(), metav1.Condition{
+		Type:    ApprovedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  ApprovedReason,
+		Message: fmt.Sprintf("Commit %s was approved by %s", sourceCommit, approvedBy),
+	})
+	return promotion
+}
+
+// PromotionBlocked sets the BlockedCondition to 'True', with the
+// BlockedByScheduleReason and the given message, and records until when the
+// Promotion is blocked, which is nil if it is unknown.
+// It returns the modified Promotion.
+func PromotionBlocked(promotion Promotion, until *metav1.Time, message string) Promotion {
+	promotion.Status.BlockedUntil = until
+	meta.SetStatusCondition(promotion.GetStatusConditions(), metav1.Condition{
+		Type:    BlockedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  BlockedByScheduleReason,
+		Message: message,
+	})
+	return promotion
+}
+
+// PromotionUnblocked removes the BlockedCondition. It returns the modified Promotion.
+func PromotionUnblocked(promotion Promotion) Promotion {
+	promotion.Status.BlockedUntil = nil
+	meta.RemoveStatusCondition(promotion.GetStatusConditions(), BlockedCondition)
+	return promotion
+}
+
+// PromotionSynced records that the target environment at targetCommit contains
+// all changes of the source environment at sourceCommit. It returns the
+// modified Promotion.
+func PromotionSynced(promotion Promotion, sourceCommit string, targetCommit string) Promotion {
+	promotion.Status.LastSyncedSourceCommitHash = sourceCommit
+	promotion.Status.LastSyncedTargetCommitHash = targetCommit
+	promotion.Status.LastPromotedSourceCommitHash = sourceCommit
+	return promotion
+}
+
+// IsSynced returns true if the changes of the last observed source
+// environment commit are known to be contained in the target environment.
+func (in *Promotion) IsSynced() bool {
+	return in.Status.ObservedSourceCommitHash != "" &&
+		in.Status.LastSyncedSourceCommitHash == in.Status.ObservedSourceCommitHash
+}
+
 // PromotionReadyMessage returns the message of the metav1.Condition of type
 // ReadyCondition with status 'True' if present, or an empty string.
 func PromotionReadyMessage(promotion Promotion) string {
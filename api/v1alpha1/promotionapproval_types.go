@@ -0,0 +1,70 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PromotionApprovalSpec defines the desired state of PromotionApproval
+type PromotionApprovalSpec struct {
+	// PromotionRef refers to the Promotion which is approved.
+	// +required
+	PromotionRef corev1.LocalObjectReference `json:"promotionRef"`
+
+	// SourceCommitHash is the full commit hash of the source environment
+	// which is approved to be promoted. A new commit of the source
+	// environment requires a new approval.
+	// +required
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{40}$`
+	SourceCommitHash string `json:"sourceCommitHash"`
+
+	// ApprovedBy is the name of the user who created the approval.
+	// It is set by the admission webhook, and can't be set by the user.
+	// +optional
+	ApprovedBy string `json:"approvedBy,omitempty"`
+
+	// Comment is an optional note of the approver.
+	// +optional
+	Comment string `json:"comment,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PromotionApproval is the Schema for the promotionapprovals API.
+// It approves a Promotion with an approval policy to promote
+// a single commit of its source environment.
+type PromotionApproval struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PromotionApprovalSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PromotionApprovalList contains a list of PromotionApproval
+type PromotionApprovalList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PromotionApproval `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PromotionApproval{}, &PromotionApprovalList{})
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func (r *PromotionApproval) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithDefaulter(&promotionApprovalDefaulter{}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-promotions-gitopsprom-io-v1alpha1-promotionapproval,mutating=true,failurePolicy=fail,sideEffects=None,groups=promotions.gitopsprom.io,resources=promotionapprovals,verbs=create,versions=v1alpha1,name=mpromotionapproval.kb.io,admissionReviewVersions=v1
+
+// promotionApprovalDefaulter records the user creating a PromotionApproval
+// as its approver, overwriting whatever the user set.
+type promotionApprovalDefaulter struct{}
+
+var _ admission.CustomDefaulter = &promotionApprovalDefaulter{}
+
+// Default implements admission.CustomDefaulter so a webhook will be registered for the type
+func (d *promotionApprovalDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	approval, ok := obj.(*PromotionApproval)
+	if !ok {
+		return fmt.Errorf("expected a PromotionApproval but got a %T", obj)
+	}
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if req.Operation == admissionv1.Create {
+		approval.Spec.ApprovedBy = req.UserInfo.Username
+	}
+	return nil
+}
+
+//+kubebuilder:webhook:path=/validate-promotions-gitopsprom-io-v1alpha1-promotionapproval,mutating=false,failurePolicy=fail,sideEffects=None,groups=promotions.gitopsprom.io,resources=promotionapprovals,verbs=create;update,versions=v1alpha1,name=vpromotionapproval.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &PromotionApproval{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *PromotionApproval) ValidateCreate() error {
+	var allErrs field.ErrorList
+	if r.Spec.ApprovedBy == "" {
+		allErrs = append(allErrs, field.Required(field.NewPath("spec", "approvedBy"), "the approver is set by the mutating webhook"))
+	}
+	return r.invalid(allErrs)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+// Approvals are immutable, a new commit requires a new approval.
+func (r *PromotionApproval) ValidateUpdate(old runtime.Object) error {
+	oldApproval, ok := old.(*PromotionApproval)
+	if !ok {
+		return fmt.Errorf("expected a PromotionApproval but got a %T", old)
+	}
+	var allErrs field.ErrorList
+	if r.Spec != oldApproval.Spec {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "approvals are immutable"))
+	}
+	return r.invalid(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *PromotionApproval) ValidateDelete() error {
+	return nil
+}
+
+func (r *PromotionApproval) invalid(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("PromotionApproval").GroupKind(), r.Name, allErrs)
+}
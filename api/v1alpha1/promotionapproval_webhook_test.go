@@ -0,0 +1,94 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestPromotionApproval_Default(t *testing.T) {
+	g := NewWithT(t)
+
+	approval := &PromotionApproval{
+		ObjectMeta: metav1.ObjectMeta{Name: "approve-dev-to-prod"},
+		Spec: PromotionApprovalSpec{
+			PromotionRef:     corev1.LocalObjectReference{Name: "dev-to-prod"},
+			SourceCommitHash: strings.Repeat("a", 40),
+			ApprovedBy:       "someone-else",
+		},
+	}
+	newContext := func(op admissionv1.Operation) context.Context {
+		return admission.NewContextWithRequest(context.Background(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: op,
+				UserInfo:  authenticationv1.UserInfo{Username: "alice@example.com"},
+			},
+		})
+	}
+
+	// The approver can't be chosen by the user.
+	d := &promotionApprovalDefaulter{}
+	g.Expect(d.Default(newContext(admissionv1.Create), approval)).To(Succeed())
+	g.Expect(approval.Spec.ApprovedBy).To(Equal("alice@example.com"))
+	g.Expect(approval.ValidateCreate()).To(Succeed())
+
+	// The approver is only recorded on creation.
+	approval.Spec.ApprovedBy = "bob@example.com"
+	g.Expect(d.Default(newContext(admissionv1.Update), approval)).To(Succeed())
+	g.Expect(approval.Spec.ApprovedBy).To(Equal("bob@example.com"))
+
+	// Without an admission request, the approver is unknown.
+	g.Expect(d.Default(context.Background(), approval)).ToNot(Succeed())
+}
+
+func TestPromotionApproval_Validate(t *testing.T) {
+	g := NewWithT(t)
+
+	approval := &PromotionApproval{
+		ObjectMeta: metav1.ObjectMeta{Name: "approve-dev-to-prod"},
+		Spec: PromotionApprovalSpec{
+			PromotionRef:     corev1.LocalObjectReference{Name: "dev-to-prod"},
+			SourceCommitHash: strings.Repeat("a", 40),
+		},
+	}
+	err := approval.ValidateCreate()
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring("spec.approvedBy"))
+
+	approval.Spec.ApprovedBy = "alice@example.com"
+	g.Expect(approval.ValidateCreate()).To(Succeed())
+
+	// Metadata may change, the spec may not.
+	updated := approval.DeepCopy()
+	updated.Labels = map[string]string{"team": "platform"}
+	g.Expect(updated.ValidateUpdate(approval)).To(Succeed())
+
+	updated.Spec.SourceCommitHash = strings.Repeat("b", 40)
+	err = updated.ValidateUpdate(approval)
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring("immutable"))
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PromotionPipelineSpec defines the desired state of PromotionPipeline
+type PromotionPipelineSpec struct {
+	// Stages is the ordered list of environments to promote through,
+	// e.g. dev, staging, prod-eu, prod-us.
+	// A Promotion is created for each hop between two consecutive stages.
+	// +required
+	// +kubebuilder:validation:MinItems=2
+	Stages []PromotionPipelineStage `json:"stages"`
+
+	// Copy defines the list of copy operations to perform on each hop.
+	// +required
+	Copy []CopyOperation `json:"copy"`
+
+	// Strategy defines the strategy to use on each hop.
+	// +required
+	// +kubebuilder:validation:Enum=pull-request;push
+	Strategy string `json:"strategy"`
+}
+
+// PromotionPipelineStage defines a stage of a PromotionPipeline.
+type PromotionPipelineStage struct {
+	// The environment of this stage.
+	// +required
+	EnvironmentRef corev1.LocalObjectReference `json:"environmentRef"`
+
+	// Copy overrides the copy operations of the hop into this stage.
+	// It is ignored on the first stage.
+	// +optional
+	Copy []CopyOperation `json:"copy,omitempty"`
+
+	// Strategy overrides the strategy of the hop into this stage.
+	// It is ignored on the first stage.
+	// +optional
+	// +kubebuilder:validation:Enum=pull-request;push
+	Strategy string `json:"strategy,omitempty"`
+}
+
+// PromotionPipelineStatus defines the observed state of PromotionPipeline
+type PromotionPipelineStatus struct {
+	// ObservedGeneration is the last observed generation of the PromotionPipeline
+	// object.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions is a list of the current conditions of the PromotionPipeline.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Stages shows where changes currently sit in the pipeline.
+	// +optional
+	Stages []PromotionPipelineStageStatus `json:"stages,omitempty"`
+}
+
+// PromotionPipelineStageStatus defines the observed state of a stage.
+type PromotionPipelineStageStatus struct {
+	// Environment is the name of the environment of this stage.
+	Environment string `json:"environment"`
+
+	// ObservedCommitHash is the commit hash last observed in the environment.
+	// +optional
+	ObservedCommitHash string `json:"observedCommitHash,omitempty"`
+
+	// Promotion is the name of the Promotion into this stage.
+	// It is empty on the first stage.
+	// +optional
+	Promotion string `json:"promotion,omitempty"`
+
+	// Phase is the phase of the stage, one of Source, Waiting, Promoting or Synced.
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// LastSyncedSourceCommitHash is the last commit hash of the previous stage
+	// whose changes are known to be contained in this stage.
+	// +optional
+	LastSyncedSourceCommitHash string `json:"lastSyncedSourceCommitHash,omitempty"`
+
+	// PullRequestURL is the URL of the last pull request into this stage.
+	// +optional
+	PullRequestURL string `json:"pullRequestUrl,omitempty"`
+}
+
+const (
+	// StagePhaseSource is the phase of the first stage of a pipeline.
+	StagePhaseSource string = "Source"
+
+	// StagePhaseWaiting is the phase of a stage whose previous stage is not in sync yet.
+	StagePhaseWaiting string = "Waiting"
+
+	// StagePhasePromoting is the phase of a stage which is being promoted to.
+	StagePhasePromoting string = "Promoting"
+
+	// StagePhaseSynced is the phase of a stage which is in sync with its previous stage.
+	StagePhaseSynced string = "Synced"
+)
+
+// PromotionPipelineReady sets the ReadyCondition to 'True', with the given reason and message.
+// It returns the modified PromotionPipeline.
+func PromotionPipelineReady(pipeline PromotionPipeline, reason string, message string) PromotionPipeline {
+	newCondition := metav1.Condition{
+		Type:    ReadyCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	}
+	meta.SetStatusCondition(pipeline.GetStatusConditions(), newCondition)
+	return pipeline
+}
+
+// PromotionPipelineNotReady sets the ReadyCondition on the PromotionPipeline to 'False', with
+// the given reason and message. It returns the modified PromotionPipeline.
+func PromotionPipelineNotReady(pipeline PromotionPipeline, reason string, message string) PromotionPipeline {
+	newCondition := metav1.Condition{
+		Type:    ReadyCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}
+	meta.SetStatusCondition(pipeline.GetStatusConditions(), newCondition)
+	return pipeline
+}
+
+// GetStatusConditions returns a pointer to the Status.Conditions slice
+func (in *PromotionPipeline) GetStatusConditions() *[]metav1.Condition {
+	return &in.Status.Conditions
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// PromotionPipeline is the Schema for the promotionpipelines API
+type PromotionPipeline struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PromotionPipelineSpec   `json:"spec,omitempty"`
+	Status PromotionPipelineStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PromotionPipelineList contains a list of PromotionPipeline
+type PromotionPipelineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PromotionPipeline `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PromotionPipeline{}, &PromotionPipelineList{})
+}
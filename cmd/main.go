@@ -19,11 +19,13 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -33,6 +35,8 @@ import (
 
 	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
 	"github.com/thomasstxyz/gitops-promotions-operator/internal/controller"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/gitcache"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/webhook"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -52,11 +56,35 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var receiverAddr string
+	var promotionRequeueInterval time.Duration
+	var promotionNotReadyRequeueInterval time.Duration
+	var gitCacheDir string
+	var gitCacheMaxSize string
+	var gitCacheMaxAge time.Duration
+	var requireKnownHosts bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&receiverAddr, "receiver-bind-address", "",
+		"The address the push webhook receiver binds to, e.g. \":9292\". "+
+			"The receiver is disabled if empty. The webhook secret is read from the RECEIVER_SECRET environment variable.")
+	flag.DurationVar(&promotionRequeueInterval, "promotion-requeue-interval", controller.DefaultPromotionRequeueInterval,
+		"The interval at which promotions are reconciled, in case a change of their environments was missed.")
+	flag.DurationVar(&promotionNotReadyRequeueInterval, "promotion-not-ready-requeue-interval", controller.DefaultEnvironmentNotReadyRequeueInterval,
+		"The interval at which promotions are reconciled while their environments are not ready.")
+	flag.StringVar(&gitCacheDir, "git-cache-dir", "",
+		"The directory the mirrors of the environment repositories are cached in, e.g. on an emptyDir volume. "+
+			"The cache is disabled if empty.")
+	flag.StringVar(&gitCacheMaxSize, "git-cache-max-size", "1Gi",
+		"The size above which the least recently used mirrors are evicted from the cache, e.g. \"512Mi\". Unlimited if zero.")
+	flag.DurationVar(&gitCacheMaxAge, "git-cache-max-age", 24*time.Hour,
+		"The duration after which unused mirrors are evicted from the cache. Unlimited if zero.")
+	flag.BoolVar(&requireKnownHosts, "require-known-hosts", false,
+		"Refuse to connect to SSH servers whose host key can't be verified, "+
+			"instead of accepting any host key if the secret of an Environment has no known_hosts.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -89,22 +117,86 @@ func main() {
 		os.Exit(1)
 	}
 
+	var gitCache *gitcache.Cache
+	if gitCacheDir != "" {
+		maxSize, err := resource.ParseQuantity(gitCacheMaxSize)
+		if err != nil {
+			setupLog.Error(err, "invalid git cache max size")
+			os.Exit(1)
+		}
+		gitCache, err = gitcache.New(gitCacheDir, maxSize.Value(), gitCacheMaxAge)
+		if err != nil {
+			setupLog.Error(err, "unable to set up git cache")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&controller.EnvironmentReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:            mgr.GetClient(),
+		Scheme:            mgr.GetScheme(),
+		GitCache:          gitCache,
+		RequireKnownHosts: requireKnownHosts,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Environment")
 		os.Exit(1)
 	}
 	if err = (&controller.PromotionReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		RequeueInterval:         promotionRequeueInterval,
+		NotReadyRequeueInterval: promotionNotReadyRequeueInterval,
+		GitCache:                gitCache,
+		RequireKnownHosts:       requireKnownHosts,
+		WebhooksDisabled:        os.Getenv("ENABLE_WEBHOOKS") == "false",
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Promotion")
 		os.Exit(1)
 	}
+	if err = (&controller.PromotionPipelineReconciler{
+		Client:            mgr.GetClient(),
+		Scheme:            mgr.GetScheme(),
+		GitCache:          gitCache,
+		RequireKnownHosts: requireKnownHosts,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PromotionPipeline")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&controller.EnvironmentValidator{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Environment")
+			os.Exit(1)
+		}
+		if err = (&controller.PromotionValidator{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Promotion")
+			os.Exit(1)
+		}
+		if err = (&promotionsv1alpha1.PromotionApproval{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PromotionApproval")
+			os.Exit(1)
+		}
+		if err = (&controller.FreezeWindowValidator{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "FreezeWindow")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
+	if receiverAddr != "" {
+		secret := os.Getenv("RECEIVER_SECRET")
+		if secret == "" {
+			setupLog.Error(nil, "the RECEIVER_SECRET environment variable is required by the webhook receiver")
+			os.Exit(1)
+		}
+		if err := mgr.Add(&webhook.Receiver{
+			Client: mgr.GetClient(),
+			Addr:   receiverAddr,
+			Secret: []byte(secret),
+		}); err != nil {
+			setupLog.Error(err, "unable to set up webhook receiver")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
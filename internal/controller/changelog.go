@@ -0,0 +1,136 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+// MaxChangelogCommits is the maximum number of commits listed in the
+// description of a pull request.
+const MaxChangelogCommits = 50
+
+// maxChangelogWalk is the maximum number of commits walked to list the
+// changelog, so the whole history isn't walked to count the omitted commits.
+var maxChangelogWalk = 1000
+
+// ChangelogCommit is a commit of the source environment listed in the
+// description of a pull request.
+type ChangelogCommit struct {
+	Hash        string
+	ShortHash   string
+	Subject     string
+	Message     string
+	Author      string
+	AuthorEmail string
+	Date        time.Time
+	// URL is the URL of the commit in the web interface of the git provider,
+	// or empty if it is unknown.
+	URL string
+}
+
+// Changelog lists the commits of the source environment being promoted.
+type Changelog struct {
+	// Commits are the listed commits, newest first.
+	Commits []ChangelogCommit
+	// Omitted is the number of commits not listed in Commits,
+	// as there are more than MaxChangelogCommits.
+	Omitted int
+	// Truncated is set if the walk stopped at maxChangelogWalk commits,
+	// so there are more than Omitted omitted commits.
+	Truncated bool
+	// FromFound is set if the commits start after the commit from.
+	FromFound bool
+}
+
+// SourceChangelog returns the commits of the source environment after the
+// commit with the hash from, up to and including the commit to.
+// If from is empty or not an ancestor of to, the history of to is returned.
+// At most MaxChangelogCommits commits are listed, and at most maxChangelogWalk
+// commits are walked.
+func SourceChangelog(obj *promotionsv1alpha1.Environment, repo *gogit.Repository, from string, to *object.Commit) (Changelog, error) {
+	iter, err := repo.Log(&gogit.LogOptions{From: to.Hash})
+	if err != nil {
+		return Changelog{}, err
+	}
+	defer iter.Close()
+
+	var changelog Changelog
+	walked := 0
+	err = iter.ForEach(func(c *object.Commit) error {
+		if c.Hash.String() == from {
+			changelog.FromFound = true
+			return storer.ErrStop
+		}
+		if walked == maxChangelogWalk {
+			changelog.Truncated = true
+			return storer.ErrStop
+		}
+		walked++
+		if len(changelog.Commits) == MaxChangelogCommits {
+			changelog.Omitted++
+			return nil
+		}
+		changelog.Commits = append(changelog.Commits, NewChangelogCommit(obj, c))
+		return nil
+	})
+	if err != nil && !errors.Is(err, storer.ErrStop) {
+		return Changelog{}, err
+	}
+	return changelog, nil
+}
+
+// NewChangelogCommit returns the ChangelogCommit of the commit c of the Environment.
+func NewChangelogCommit(obj *promotionsv1alpha1.Environment, c *object.Commit) ChangelogCommit {
+	hash := c.Hash.String()
+	return ChangelogCommit{
+		Hash:        hash,
+		ShortHash:   hash[0:7],
+		Subject:     strings.TrimSpace(strings.SplitN(c.Message, "\n", 2)[0]),
+		Message:     c.Message,
+		Author:      c.Author.Name,
+		AuthorEmail: c.Author.Email,
+		Date:        c.Author.When,
+		URL:         CommitURL(obj, hash),
+	}
+}
+
+// CommitURL returns the URL of the commit in the web interface of the git
+// provider of the Environment, or an empty string if the repository is
+// neither served over HTTP(S) nor SSH.
+func CommitURL(obj *promotionsv1alpha1.Environment, hash string) string {
+	u, err := url.Parse(RepositoryWebURL(obj.Spec.Source.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	u.User = nil
+	repoURL := strings.TrimSuffix(strings.TrimSuffix(u.String(), "/"), ".git")
+
+	if obj.Spec.GitProvider == promotionsv1alpha1.GitProviderGitLab {
+		return repoURL + "/-/commit/" + hash
+	}
+	return repoURL + "/commit/" + hash
+}
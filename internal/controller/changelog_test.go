@@ -0,0 +1,149 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+func TestSourceChangelog(t *testing.T) {
+	g := NewWithT(t)
+
+	url := newTestRepository(t, map[string]string{"version.yaml": "version: 1.0.0\n"})
+	var hashes []string
+	for i := 1; i <= MaxChangelogCommits+2; i++ {
+		hashes = append(hashes, commitTestRepository(t, url, map[string]string{
+			"version.yaml": fmt.Sprintf("version: 1.%d.0\n", i),
+		}))
+	}
+
+	repo, err := gogit.CloneContext(context.Background(), memory.NewStorage(), nil, &gogit.CloneOptions{
+		URL:           url,
+		ReferenceName: plumbing.NewBranchReferenceName("master"),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	head, err := repo.CommitObject(plumbing.NewHash(hashes[len(hashes)-1]))
+	g.Expect(err).ToNot(HaveOccurred())
+	environment := &promotionsv1alpha1.Environment{
+		Spec: promotionsv1alpha1.EnvironmentSpec{Source: promotionsv1alpha1.Source{URL: url}},
+	}
+
+	// The commits after the last promoted commit, newest first.
+	changelog, err := SourceChangelog(environment, repo, hashes[len(hashes)-3], head)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changelog.FromFound).To(BeTrue())
+	g.Expect(changelog.Omitted).To(BeZero())
+	g.Expect(changelog.Commits).To(HaveLen(2))
+	commits := changelog.Commits
+	g.Expect(commits[0].Hash).To(Equal(hashes[len(hashes)-1]))
+	g.Expect(commits[0].ShortHash).To(Equal(hashes[len(hashes)-1][0:7]))
+	g.Expect(commits[0].Subject).To(Equal("update"))
+	g.Expect(commits[0].Author).To(Equal("Test"))
+	g.Expect(commits[0].URL).To(BeEmpty())
+
+	// Without a last promoted commit, the history is listed up to the limit.
+	changelog, err = SourceChangelog(environment, repo, "", head)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changelog.Commits).To(HaveLen(MaxChangelogCommits))
+	g.Expect(changelog.Omitted).To(Equal(3))
+	g.Expect(changelog.Truncated).To(BeFalse())
+
+	// A last promoted commit which is not in the history is not found.
+	changelog, err = SourceChangelog(environment, repo, "0123456789012345678901234567890123456789", head)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changelog.FromFound).To(BeFalse())
+	g.Expect(changelog.Omitted).To(Equal(3))
+
+	// Long histories are only walked up to the limit.
+	defer func(walk int) { maxChangelogWalk = walk }(maxChangelogWalk)
+	maxChangelogWalk = MaxChangelogCommits + 1
+	changelog, err = SourceChangelog(environment, repo, "0123456789012345678901234567890123456789", head)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changelog.FromFound).To(BeFalse())
+	g.Expect(changelog.Truncated).To(BeTrue())
+	g.Expect(changelog.Commits).To(HaveLen(MaxChangelogCommits))
+	g.Expect(changelog.Omitted).To(Equal(1))
+}
+
+func TestCommitURL(t *testing.T) {
+	tests := []struct {
+		url         string
+		gitProvider string
+		want        string
+	}{
+		{url: "https://github.com/org/repo", gitProvider: promotionsv1alpha1.GitProviderGitHub, want: "https://github.com/org/repo/commit/abc"},
+		{url: "https://gitlab.example.com/group/repo.git", gitProvider: promotionsv1alpha1.GitProviderGitLab, want: "https://gitlab.example.com/group/repo/-/commit/abc"},
+		{url: "https://token@gitea.example.com/org/repo/", want: "https://gitea.example.com/org/repo/commit/abc"},
+		{url: "git@github.com:org/repo.git", want: "https://github.com/org/repo/commit/abc"},
+		{url: "file:///tmp/repo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			g := NewWithT(t)
+			environment := &promotionsv1alpha1.Environment{
+				Spec: promotionsv1alpha1.EnvironmentSpec{Source: promotionsv1alpha1.Source{URL: tt.url}, GitProvider: tt.gitProvider},
+			}
+			g.Expect(CommitURL(environment, "abc")).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestPullRequestBody(t *testing.T) {
+	g := NewWithT(t)
+
+	body, err := PullRequestBody(TemplateData{
+		Prom:       &promotionsv1alpha1.Promotion{ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod"}},
+		SourceEnv:  &promotionsv1alpha1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "dev"}},
+		TargetEnv:  &promotionsv1alpha1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "prod"}},
+		FromCommit: "1111111",
+		ToCommit:   "3333333",
+		Commits: []ChangelogCommit{
+			{ShortHash: "3333333", Subject: "Bump app to 1.2.0", Author: "Jane", URL: "https://github.com/org/dev/commit/3333333"},
+			{ShortHash: "2222222", Subject: "Tune replicas", Author: "John"},
+		},
+		OmittedCommits:     4,
+		MoreOmittedCommits: true,
+		CopyOperations: []CopyOperationChanges{
+			{Name: "Application Version", Files: []string{"envs/prod/app-version/version.yaml"}},
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(body).To(Equal("Promotes dev to prod.\n" +
+		"\n" +
+		"### Changelog\n" +
+		"\n" +
+		"Commits of dev from `1111111` to `3333333`:\n" +
+		"\n" +
+		"- [`3333333`](https://github.com/org/dev/commit/3333333) Bump app to 1.2.0 (Jane)\n" +
+		"- `2222222` Tune replicas (John)\n" +
+		"- ... and 4+ more\n" +
+		"\n" +
+		"### Changed files\n" +
+		"\n" +
+		"**Application Version**\n" +
+		"- `envs/prod/app-version/version.yaml`\n"))
+}
@@ -18,28 +18,46 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	"github.com/fluxcd/go-git-providers/github"
+	"github.com/fluxcd/go-git-providers/gitlab"
 	"github.com/fluxcd/go-git-providers/gitprovider"
 
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	gogitclient "github.com/go-git/go-git/v5/plumbing/transport/client"
+	gogithttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	gogitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
 
 	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/gitcache"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/githubapp"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/provider"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/semver"
 	"github.com/thomasstxyz/gitops-promotions-operator/internal/util"
 )
 
@@ -47,6 +65,14 @@ import (
 type EnvironmentReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// GitCache is the cache the repositories are cloned from.
+	// Repositories are cloned from their remote if nil.
+	GitCache *gitcache.Cache
+
+	// RequireKnownHosts refuses to connect to SSH servers whose host key
+	// can't be verified against the known hosts of the secret of the Environment.
+	RequireKnownHosts bool
 }
 
 //+kubebuilder:rbac:groups=promotions.gitopsprom.io,resources=environments,verbs=get;list;watch;create;update;patch;delete
@@ -73,6 +99,30 @@ func (r *EnvironmentReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 	}()
 
+	if err := ValidateSourceURL(obj.Spec.Source.URL); err != nil {
+		// Retrying won't help, the Environment is reconciled again once its URL changes.
+		*obj = promotionsv1alpha1.EnvironmentNotReady(*obj, promotionsv1alpha1.InvalidURLReason, err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	// Check for new commits without cloning the repository
+	latestRef, err := GitLsRemoteEnvironment(ctx, r.Client, r.RequireKnownHosts, obj)
+	if err != nil {
+		if IsHostKeyError(err) {
+			*obj = promotionsv1alpha1.EnvironmentNotReady(*obj, promotionsv1alpha1.HostKeyVerificationFailedReason, err.Error())
+		}
+		return ctrl.Result{}, err
+	}
+	now := metav1.Now()
+	obj.Status.LastCheckedTime = &now
+
+	if obj.IsReady() && obj.Status.ObservedGeneration == obj.Generation && obj.Status.ObservedRef == latestRef.Name().String() && obj.Status.ObservedCommitHash == latestRef.Hash().String() {
+		log.Info("No new commits in Environment", "ref", latestRef.Name().String(), "commit", latestRef.Hash().String(), "nextReconcile", obj.GetInterval())
+		return ctrl.Result{
+			RequeueAfter: obj.GetInterval(),
+		}, nil
+	}
+
 	// Check if we can clone the repository
 
 	tmpDir, err := util.TempDirForObj("", obj)
@@ -81,7 +131,7 @@ func (r *EnvironmentReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 	defer os.RemoveAll(tmpDir)
 
-	repo, err := GitCloneEnvironment(ctx, r.Client, obj, tmpDir)
+	repo, err := GitCloneEnvironmentRef(ctx, r.Client, r.GitCache, r.RequireKnownHosts, obj, latestRef, tmpDir)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
@@ -99,24 +149,69 @@ func (r *EnvironmentReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	// If we reach this far, we assume that the environment is ready
 
 	*obj = promotionsv1alpha1.EnvironmentReady(*obj, promotionsv1alpha1.SucceededReason, "Authentication works, cloned repo successfully.", commit.String())
+	obj.Status.ObservedRef = latestRef.Name().String()
 
 	end := time.Now()
-	log.Info("Reconciled Environment successfully", "duration", end.Sub(start))
+	log.Info("Reconciled Environment successfully", "duration", end.Sub(start), "nextReconcile", obj.GetInterval())
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{
+		RequeueAfter: obj.GetInterval(),
+	}, nil
 }
 
-func SetupGitAuthEnvironment(ctx context.Context, client client.Client, obj *promotionsv1alpha1.Environment) (gitAuthOpts transport.AuthMethod, cloneURL string, err error) {
+// SetupGitAuthEnvironment returns the auth method and URL to clone the
+// repository of the Environment with. The auth method is detected from the
+// contents of the secret referenced by the Environment: a GitHub App, an SSH
+// private key in "private", a token in "bearerToken", or a "username" and
+// "password" for HTTPS.
+// If requireKnownHosts is true, the secret of an SSH key must contain the
+// known hosts to verify the host key of the SSH server with.
+func SetupGitAuthEnvironment(ctx context.Context, client client.Client, requireKnownHosts bool, obj *promotionsv1alpha1.Environment) (gitAuthOpts transport.AuthMethod, cloneURL string, err error) {
 	cloneURL = obj.Spec.Source.URL
+	if err := ValidateSourceURL(cloneURL); err != nil {
+		return gitAuthOpts, cloneURL, err
+	}
 
-	// If we have a secret, we use SSH with auth options to clone the repository
-	if obj.Spec.Source.SecretRef != nil {
-		sshSecret := &corev1.Secret{}
-		if err := client.Get(ctx, types.NamespacedName{Name: obj.Spec.Source.SecretRef.Name, Namespace: obj.Namespace}, sshSecret); err != nil {
+	if obj.Spec.Source.SecretRef == nil {
+		return gitAuthOpts, cloneURL, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := client.Get(ctx, types.NamespacedName{Name: obj.Spec.Source.SecretRef.Name, Namespace: obj.Namespace}, secret); err != nil {
+		return gitAuthOpts, cloneURL, err
+	}
+
+	app, err := GitHubApp(secret)
+	if err != nil {
+		return gitAuthOpts, cloneURL, err
+	}
+
+	switch {
+	case app != nil:
+		token, err := githubapp.DefaultCache.Token(ctx, *app)
+		if err != nil {
 			return gitAuthOpts, cloneURL, err
 		}
-
-		sshSigner, err := ssh.ParsePrivateKey(sshSecret.Data["private"])
+		// GitHub expects installation access tokens as password of this user.
+		gitAuthOpts = &gogithttp.BasicAuth{
+			Username: "x-access-token",
+			Password: token,
+		}
+	case len(secret.Data["private"]) > 0:
+		// The URL is used as written, so it has to be an SSH URL.
+		endpoint, err := transport.NewEndpoint(cloneURL)
+		if err != nil {
+			return gitAuthOpts, cloneURL, err
+		}
+		if endpoint.Protocol != "ssh" {
+			return gitAuthOpts, cloneURL, fmt.Errorf("secret %s contains an SSH key, but %s is not an SSH URL, "+
+				"e.g. ssh://git@example.com/org/repo.git or git@example.com:org/repo.git", secret.Name, cloneURL)
+		}
+		sshSigner, err := ssh.ParsePrivateKey(secret.Data["private"])
+		if err != nil {
+			return gitAuthOpts, cloneURL, err
+		}
+		hostKeyCallback, err := HostKeyCallback(secret, requireKnownHosts)
 		if err != nil {
 			return gitAuthOpts, cloneURL, err
 		}
@@ -124,25 +219,199 @@ func SetupGitAuthEnvironment(ctx context.Context, client client.Client, obj *pro
 			User:   "git",
 			Signer: sshSigner,
 			HostKeyCallbackHelper: gogitssh.HostKeyCallbackHelper{
-				HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+				HostKeyCallback: hostKeyCallback,
 			},
 		}
-		cloneURL = strings.Replace(cloneURL, "https://", "git@", 1)
-		cloneURL = strings.Replace(cloneURL, ".com/", ".com:", 1)
+	case len(secret.Data["bearerToken"]) > 0:
+		gitAuthOpts = &gogithttp.TokenAuth{
+			Token: string(secret.Data["bearerToken"]),
+		}
+	case len(secret.Data["password"]) > 0:
+		username := string(secret.Data["username"])
+		if username == "" {
+			// Most git providers accept any username along with a token as password.
+			username = "git"
+		}
+		gitAuthOpts = &gogithttp.BasicAuth{
+			Username: username,
+			Password: string(secret.Data["password"]),
+		}
+	default:
+		return gitAuthOpts, cloneURL, fmt.Errorf("secret %s contains neither an SSH key in \"private\", nor a \"bearerToken\" or \"password\"", secret.Name)
 	}
 
 	return gitAuthOpts, cloneURL, nil
 }
 
-func GitCloneEnvironment(ctx context.Context, client client.Client, obj *promotionsv1alpha1.Environment, tmpDir string) (*gogit.Repository, error) {
-	gitAuthOpts, cloneURL, err := SetupGitAuthEnvironment(ctx, client, obj)
+// ValidateSourceURL checks that the URL of the repository of an Environment
+// doesn't use the scheme the git cache serves its mirrors with, as these could
+// be read without authentication.
+func ValidateSourceURL(rawURL string) error {
+	if u, err := url.Parse(rawURL); err == nil && strings.EqualFold(u.Scheme, gitcache.Scheme) {
+		return fmt.Errorf("the %q scheme is reserved for the git cache of the operator", gitcache.Scheme)
+	}
+	return nil
+}
+
+// RepositoryWebURL returns the HTTPS URL of a repository given by its SSH URL,
+// e.g. "https://github.com/org/repo" for "git@github.com:org/repo.git", as the
+// APIs of the git providers identify repositories by it.
+// Any other URL is returned as written.
+func RepositoryWebURL(rawURL string) string {
+	endpoint, err := transport.NewEndpoint(rawURL)
+	if err != nil || endpoint.Protocol != "ssh" {
+		return rawURL
+	}
+	return "https://" + endpoint.Host + "/" + strings.TrimSuffix(strings.Trim(endpoint.Path, "/"), ".git")
+}
+
+// HostKeyError is returned if the host key of an SSH server can't be verified.
+type HostKeyError struct {
+	Message string
+	// Err is the error of the known hosts callback, if any.
+	Err error
+}
+
+func (e *HostKeyError) Error() string {
+	return e.Message
+}
+
+func (e *HostKeyError) Unwrap() error {
+	return e.Err
+}
+
+// IsHostKeyError returns true if the error was caused by the verification of
+// the host key of an SSH server.
+func IsHostKeyError(err error) bool {
+	var hostKeyErr *HostKeyError
+	var keyErr *knownhosts.KeyError
+	return errors.As(err, &hostKeyErr) || errors.As(err, &keyErr)
+}
+
+// hostKeyRecorder keeps the error of a failed host key verification, as the
+// SSH client only keeps the message of the errors of the host key callback.
+type hostKeyRecorder struct {
+	mu  sync.Mutex
+	err error
+}
+
+// recordHostKeyErrors wraps the host key callback of SSH auth methods, so that
+// wrap can tell failed verifications apart. Other auth methods are left as is.
+func recordHostKeyErrors(auth transport.AuthMethod) *hostKeyRecorder {
+	r := &hostKeyRecorder{}
+	publicKeys, ok := auth.(*gogitssh.PublicKeys)
+	if !ok || publicKeys.HostKeyCallback == nil {
+		return r
+	}
+	callback := publicKeys.HostKeyCallback
+	publicKeys.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		// go-git calls the callback with a placeholder key to look up the
+		// algorithms of the known host keys, which is not a verification.
+		if _, parseErr := ssh.ParsePublicKey(key.Marshal()); parseErr != nil {
+			return err
+		}
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			r.mu.Lock()
+			r.err = err
+			r.mu.Unlock()
+		}
+		return err
+	}
+	return r
+}
+
+// wrap returns err as HostKeyError if the host key verification failed.
+func (r *hostKeyRecorder) wrap(err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil || r.err == nil {
+		return err
+	}
+	return &HostKeyError{Message: err.Error(), Err: r.err}
+}
+
+// HostKeyCallback returns the callback verifying the host keys of SSH servers
+// against the "known_hosts" key of the secret, in the format of OpenSSH's
+// known_hosts file. Host keys are not verified if the secret has no known
+// hosts, unless requireKnownHosts is true.
+func HostKeyCallback(secret *corev1.Secret, requireKnownHosts bool) (ssh.HostKeyCallback, error) {
+	knownHosts := secret.Data["known_hosts"]
+	if len(knownHosts) == 0 {
+		if requireKnownHosts {
+			return nil, &HostKeyError{Message: fmt.Sprintf("secret %s has no known_hosts to verify the host key of the SSH server with", secret.Name)}
+		}
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	// go-git reads the known hosts from files.
+	f, err := os.CreateTemp("", "known_hosts-")
 	if err != nil {
 		return nil, err
 	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(knownHosts); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return gogitssh.NewKnownHostsCallback(f.Name())
+}
+
+// GitCloneEnvironment clones the branch of the Environment into tmpDir.
+// If gitCache is not nil, the branch is fetched into its mirror in the cache,
+// and cloned from there.
+func GitCloneEnvironment(ctx context.Context, client client.Client, gitCache *gitcache.Cache, requireKnownHosts bool, obj *promotionsv1alpha1.Environment, tmpDir string) (*gogit.Repository, error) {
+	gitAuthOpts, cloneURL, err := SetupGitAuthEnvironment(ctx, client, requireKnownHosts, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return gitClone(ctx, gitCache, cloneURL, plumbing.NewBranchReferenceName(obj.GetBranch()), gitAuthOpts, tmpDir)
+}
+
+// GitCloneEnvironmentRef clones the Environment at the reference resolved by
+// GitLsRemoteEnvironment into tmpDir, and checks out the commit of the
+// reference. Unlike GitCloneEnvironment, this honours the tag, semver range
+// and commit of the Environment, and is used for the Environment as source.
+func GitCloneEnvironmentRef(ctx context.Context, client client.Client, gitCache *gitcache.Cache, requireKnownHosts bool, obj *promotionsv1alpha1.Environment, ref *plumbing.Reference, tmpDir string) (*gogit.Repository, error) {
+	gitAuthOpts, cloneURL, err := SetupGitAuthEnvironment(ctx, client, requireKnownHosts, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := gitClone(ctx, gitCache, cloneURL, ref.Name(), gitAuthOpts, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pinned commits are cloned with their branch, and checked out afterwards.
+	if obj.Spec.Source.Reference != nil && obj.Spec.Source.Reference.Commit != "" {
+		wt, err := repo.Worktree()
+		if err != nil {
+			return nil, err
+		}
+		if err := wt.Checkout(&gogit.CheckoutOptions{Hash: ref.Hash()}); err != nil {
+			return nil, fmt.Errorf("failed to check out commit %s of branch %q: %w", ref.Hash(), ref.Name().Short(), err)
+		}
+	}
+	return repo, nil
+}
+
+// gitClone clones the reference of the repository at cloneURL into tmpDir.
+// If gitCache is not nil, the reference is fetched into its mirror in the
+// cache, and cloned from there.
+func gitClone(ctx context.Context, gitCache *gitcache.Cache, cloneURL string, ref plumbing.ReferenceName, gitAuthOpts transport.AuthMethod, tmpDir string) (*gogit.Repository, error) {
+	if gitCache != nil {
+		return gitCache.CheckoutReference(ctx, cloneURL, ref, gitAuthOpts, tmpDir)
+	}
 
 	repo, err := gogit.PlainClone(tmpDir, false, &gogit.CloneOptions{
 		URL:           cloneURL,
-		ReferenceName: plumbing.NewBranchReferenceName(obj.GetBranch()),
+		ReferenceName: ref,
 		Auth:          gitAuthOpts,
 	})
 	if err != nil {
@@ -152,50 +421,231 @@ func GitCloneEnvironment(ctx context.Context, client client.Client, obj *promoti
 	return repo, nil
 }
 
+// GitLsRemoteEnvironment resolves the reference of the Environment by listing
+// the references of the remote repository instead of cloning it.
+// The hash of the returned reference is the commit the Environment points to,
+// see ResolveReference.
+func GitLsRemoteEnvironment(ctx context.Context, client client.Client, requireKnownHosts bool, obj *promotionsv1alpha1.Environment) (*plumbing.Reference, error) {
+	gitAuthOpts, cloneURL, err := SetupGitAuthEnvironment(ctx, client, requireKnownHosts, obj)
+	if err != nil {
+		return nil, err
+	}
+	hostKeys := recordHostKeyErrors(gitAuthOpts)
+
+	// The session is used instead of Remote.List, which drops the peeled
+	// hashes of annotated tags.
+	endpoint, err := transport.NewEndpoint(cloneURL)
+	if err != nil {
+		return nil, err
+	}
+	gitClient, err := gogitclient.NewClient(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	session, err := gitClient.NewUploadPackSession(endpoint, gitAuthOpts)
+	if err != nil {
+		return nil, hostKeys.wrap(err)
+	}
+	defer session.Close()
+	advRefs, err := session.AdvertisedReferencesContext(ctx)
+	if err != nil {
+		return nil, hostKeys.wrap(err)
+	}
+
+	return ResolveReference(obj, advRefs)
+}
+
+// ResolveReference resolves the reference of the Environment against the
+// references advertised by its remote repository. In order of precedence,
+// this is the branch with the pinned commit as hash, the highest tag in the
+// semver range, the tag, or the branch. Annotated tags are peeled to their
+// commit, if the remote advertises the peeled hashes.
+func ResolveReference(obj *promotionsv1alpha1.Environment, advRefs *packp.AdvRefs) (*plumbing.Reference, error) {
+	ref := obj.Spec.Source.Reference
+	if ref == nil {
+		ref = &promotionsv1alpha1.GitRepositoryRef{}
+	}
+	branch := plumbing.NewBranchReferenceName(obj.GetBranch())
+
+	switch {
+	case ref.Commit != "":
+		if !plumbing.IsHash(ref.Commit) {
+			return nil, fmt.Errorf("commit %q is not a full SHA-1 hash", ref.Commit)
+		}
+		if _, ok := advRefs.References[branch.String()]; !ok {
+			return nil, fmt.Errorf("branch %q not found in %s", branch.Short(), obj.Spec.Source.URL)
+		}
+		return plumbing.NewHashReference(branch, plumbing.NewHash(ref.Commit)), nil
+	case ref.SemVer != "":
+		rng, err := semver.ParseRange(ref.SemVer)
+		if err != nil {
+			return nil, err
+		}
+		var tags []string
+		for name := range advRefs.References {
+			if refName := plumbing.ReferenceName(name); refName.IsTag() {
+				tags = append(tags, refName.Short())
+			}
+		}
+		// Sorted, so that the same tag is picked among equal versions.
+		sort.Strings(tags)
+		tag, ok := semver.Latest(rng, tags)
+		if !ok {
+			return nil, fmt.Errorf("no tag in semver range %q found in %s", ref.SemVer, obj.Spec.Source.URL)
+		}
+		return peeledReference(obj, advRefs, plumbing.NewTagReferenceName(tag))
+	case ref.Tag != "":
+		return peeledReference(obj, advRefs, plumbing.NewTagReferenceName(ref.Tag))
+	default:
+		return peeledReference(obj, advRefs, branch)
+	}
+}
+
+// peeledReference returns the advertised reference with the given name,
+// with the hash of the commit an annotated tag points to, if advertised.
+func peeledReference(obj *promotionsv1alpha1.Environment, advRefs *packp.AdvRefs, name plumbing.ReferenceName) (*plumbing.Reference, error) {
+	hash, ok := advRefs.References[name.String()]
+	if !ok {
+		kind := "branch"
+		if name.IsTag() {
+			kind = "tag"
+		}
+		return nil, fmt.Errorf("%s %q not found in %s", kind, name.Short(), obj.Spec.Source.URL)
+	}
+	if peeled, ok := advRefs.Peeled[name.String()]; ok {
+		hash = peeled
+	}
+	return plumbing.NewHashReference(name, hash), nil
+}
+
 func GitCommitEnvironment(ctx context.Context, client client.Client, obj *promotionsv1alpha1.Environment, tmpDir string) (*gogit.Repository, error) {
 	return nil, nil
 }
 
-func NewGitProviderOrgRepository(ctx context.Context, client client.Client, obj *promotionsv1alpha1.Environment, repo *gogit.Repository) (gitprovider.OrgRepository, error) {
-	var c gitprovider.Client
-
+// GetApiToken returns the API token from the secret referenced by
+// ApiTokenSecretRef, or an empty string if there is no such reference.
+// If the secret holds a GitHub App, an installation access token is returned.
+func GetApiToken(ctx context.Context, client client.Client, obj *promotionsv1alpha1.Environment) (string, error) {
 	tokenSecret := &corev1.Secret{}
 	if obj.Spec.ApiTokenSecretRef != nil {
 		err := client.Get(ctx, types.NamespacedName{Name: obj.Spec.ApiTokenSecretRef.Name, Namespace: obj.Namespace}, tokenSecret)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	app, err := GitHubApp(tokenSecret)
+	if err != nil {
+		return "", err
+	}
+	if app != nil {
+		return githubapp.DefaultCache.Token(ctx, *app)
+	}
+	return string(tokenSecret.Data["token"]), nil
+}
+
+// GitHubApp returns the GitHub App installation held by the secret in the keys
+// "githubAppID", "githubAppInstallationID", "githubAppPrivateKey", and optionally
+// "githubAppBaseURL", or nil if the secret holds no GitHub App.
+func GitHubApp(secret *corev1.Secret) (*githubapp.App, error) {
+	if len(secret.Data["githubAppID"]) == 0 {
+		return nil, nil
+	}
+
+	appID, err := strconv.ParseInt(strings.TrimSpace(string(secret.Data["githubAppID"])), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid githubAppID in secret %s: %w", secret.Name, err)
+	}
+	installationID, err := strconv.ParseInt(strings.TrimSpace(string(secret.Data["githubAppInstallationID"])), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid githubAppInstallationID in secret %s: %w", secret.Name, err)
+	}
+	privateKey, err := githubapp.ParsePrivateKey(secret.Data["githubAppPrivateKey"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid githubAppPrivateKey in secret %s: %w", secret.Name, err)
+	}
+
+	return &githubapp.App{
+		BaseURL:        string(secret.Data["githubAppBaseURL"]),
+		AppID:          appID,
+		InstallationID: installationID,
+		PrivateKey:     privateKey,
+	}, nil
+}
+
+// NewPullRequestProvider returns the provider.Provider for the git provider of the Environment.
+func NewPullRequestProvider(ctx context.Context, client client.Client, obj *promotionsv1alpha1.Environment, repo *gogit.Repository) (provider.Provider, error) {
+	switch obj.Spec.GitProvider {
+	case promotionsv1alpha1.GitProviderGitea, promotionsv1alpha1.GitProviderForgejo:
+		token, err := GetApiToken(ctx, client, obj)
+		if err != nil {
+			return nil, err
+		}
+		return provider.NewGitea(obj.Spec.GitProviderBaseURL, RepositoryWebURL(obj.Spec.Source.URL), token, nil)
+	default:
+		gitProviderClient, gitProviderRepo, err := NewGitProviderOrgRepository(ctx, client, obj, repo)
 		if err != nil {
 			return nil, err
 		}
+		return provider.NewGitProvider(gitProviderClient, gitProviderRepo), nil
+	}
+}
+
+// NewGitProviderOrgRepository returns the go-git-providers client for the git provider
+// of the environment, and the repository of the environment.
+func NewGitProviderOrgRepository(ctx context.Context, client client.Client, obj *promotionsv1alpha1.Environment, repo *gogit.Repository) (gitprovider.Client, gitprovider.OrgRepository, error) {
+	var c gitprovider.Client
+
+	token, err := GetApiToken(ctx, client, obj)
+	if err != nil {
+		return nil, nil, err
 	}
-	token := string(tokenSecret.Data["token"])
 
 	switch obj.Spec.GitProvider {
 	case promotionsv1alpha1.GitProviderGitHub:
-		var err error
 		c, err = github.NewClient(gitprovider.WithOAuth2Token(token))
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+	case promotionsv1alpha1.GitProviderGitLab:
+		var clientOpts []gitprovider.ClientOption
+		if obj.Spec.GitProviderBaseURL != "" {
+			clientOpts = append(clientOpts, gitprovider.WithDomain(obj.Spec.GitProviderBaseURL))
+		}
+		c, err = gitlab.NewClient(token, "", clientOpts...)
+		if err != nil {
+			return nil, nil, err
 		}
 	default:
-		fmt.Println("No Git Provider specified")
+		return nil, nil, fmt.Errorf("unsupported git provider %q", obj.Spec.GitProvider)
 	}
 
 	// Parse the URL into an OrgRepositoryRef
-	ref, err := gitprovider.ParseOrgRepositoryURL(obj.Spec.Source.URL)
+	ref, err := gitprovider.ParseOrgRepositoryURL(RepositoryWebURL(obj.Spec.Source.URL))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	// The client only accepts references to the domain it was created for,
+	// which for self-hosted instances is the base URL rather than the host.
+	if obj.Spec.GitProviderBaseURL != "" {
+		ref.Domain = c.SupportedDomain()
 	}
 	// Get public information about the git repository.
 	gitProviderRepo, err := c.OrgRepositories().Get(ctx, *ref)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return gitProviderRepo, nil
+	return c, gitProviderRepo, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *EnvironmentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Status updates, e.g. of the LastCheckedTime, must not trigger a
+	// reconciliation, while requests by the ReconcileRequestAnnotation must.
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&promotionsv1alpha1.Environment{}).
+		For(&promotionsv1alpha1.Environment{}, builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}),
+		)).
 		Complete(r)
 }
@@ -0,0 +1,553 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	billyutil "github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/gitcache"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/githubapp"
+)
+
+func TestEnvironmentReconciler(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(promotionsv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	url := newTestRepository(t, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.0.0\n",
+	})
+	environment := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec: promotionsv1alpha1.EnvironmentSpec{
+			Path:     "envs/dev",
+			Source:   promotionsv1alpha1.Source{URL: url},
+			Interval: &metav1.Duration{Duration: time.Minute},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(environment).Build()
+	r := &EnvironmentReconciler{Client: c, Scheme: scheme}
+
+	reconcile := func() *promotionsv1alpha1.Environment {
+		result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(environment)})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result.RequeueAfter).To(Equal(time.Minute))
+		obj := &promotionsv1alpha1.Environment{}
+		g.Expect(c.Get(ctx, client.ObjectKeyFromObject(environment), obj)).To(Succeed())
+		return obj
+	}
+
+	obj := reconcile()
+	g.Expect(obj.IsReady()).To(BeTrue())
+	g.Expect(obj.Status.ObservedCommitHash).ToNot(BeEmpty())
+	g.Expect(obj.Status.LastCheckedTime).ToNot(BeNil())
+	firstCommit := obj.Status.ObservedCommitHash
+
+	// Without new commits, only the time of the check is updated.
+	obj = reconcile()
+	g.Expect(obj.Status.ObservedCommitHash).To(Equal(firstCommit))
+	g.Expect(obj.Status.LastCheckedTime).ToNot(BeNil())
+
+	// New commits are observed.
+	commit := commitTestRepository(t, url, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.1.0\n",
+	})
+	obj = reconcile()
+	g.Expect(obj.Status.ObservedCommitHash).To(Equal(commit))
+
+	// The mirrors of the git cache can't be cloned.
+	obj.Spec.Source.URL = gitcache.Scheme + ":///0123abcd"
+	g.Expect(c.Update(ctx, obj)).To(Succeed())
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(environment)})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(environment), obj)).To(Succeed())
+	g.Expect(obj.IsReady()).To(BeFalse())
+	g.Expect(apimeta.FindStatusCondition(obj.Status.Conditions, promotionsv1alpha1.ReadyCondition).Reason).To(Equal(promotionsv1alpha1.InvalidURLReason))
+	_, _, err = SetupGitAuthEnvironment(ctx, c, false, obj)
+	g.Expect(err).To(HaveOccurred())
+
+	g.Expect((&promotionsv1alpha1.Environment{}).GetInterval()).To(Equal(promotionsv1alpha1.DefaultEnvironmentInterval))
+}
+
+// newTestSSHKey returns a new ed25519 key, and its private key in PEM encoding.
+func newTestSSHKey(t *testing.T) (ssh.Signer, []byte) {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// newTestSSHServer starts an SSH server which completes the handshake with
+// any client, and rejects all sessions. It returns its address and host key.
+func newTestSSHServer(t *testing.T) (string, ssh.PublicKey) {
+	t.Helper()
+
+	hostKey, _ := newTestSSHKey(t)
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for ch := range chans {
+					ch.Reject(ssh.Prohibited, "no git here")
+				}
+			}()
+		}
+	}()
+	return l.Addr().String(), hostKey.PublicKey()
+}
+
+func TestEnvironmentReconciler_KnownHosts(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(promotionsv1alpha1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	addr, hostKey := newTestSSHServer(t)
+	otherHostKey, _ := newTestSSHKey(t)
+	_, clientKey := newTestSSHKey(t)
+
+	environment := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec: promotionsv1alpha1.EnvironmentSpec{
+			Source: promotionsv1alpha1.Source{
+				URL:       "ssh://git@" + addr + "/dev.git",
+				SecretRef: &corev1.LocalObjectReference{Name: "ssh"},
+			},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "ssh", Namespace: "default"},
+		Data:       map[string][]byte{"private": clientKey},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(environment, secret).Build()
+
+	setKnownHosts := func(key ssh.PublicKey) {
+		if key != nil {
+			secret.Data["known_hosts"] = []byte(knownhosts.Line([]string{knownhosts.Normalize(addr)}, key) + "\n")
+		} else {
+			delete(secret.Data, "known_hosts")
+		}
+		g.Expect(c.Update(ctx, secret)).To(Succeed())
+	}
+	lsRemote := func(requireKnownHosts bool) error {
+		_, err := GitLsRemoteEnvironment(ctx, c, requireKnownHosts, environment)
+		return err
+	}
+
+	// An unknown host key is refused, and the Environment is not ready.
+	setKnownHosts(otherHostKey.PublicKey())
+	err := lsRemote(false)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(IsHostKeyError(err)).To(BeTrue())
+	var keyErr *knownhosts.KeyError
+	g.Expect(errors.As(err, &keyErr)).To(BeTrue())
+	g.Expect(keyErr.Want).ToNot(BeEmpty())
+
+	r := &EnvironmentReconciler{Client: c, Scheme: scheme}
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(environment)})
+	g.Expect(err).To(HaveOccurred())
+	obj := &promotionsv1alpha1.Environment{}
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(environment), obj)).To(Succeed())
+	condition := apimeta.FindStatusCondition(obj.Status.Conditions, promotionsv1alpha1.ReadyCondition)
+	g.Expect(condition).ToNot(BeNil())
+	g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(condition.Reason).To(Equal(promotionsv1alpha1.HostKeyVerificationFailedReason))
+
+	// A known host key passes the verification. The test server has no repositories though.
+	setKnownHosts(hostKey)
+	err = lsRemote(true)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(IsHostKeyError(err)).To(BeFalse())
+
+	// Without known hosts, any host key is accepted, unless known hosts are required.
+	setKnownHosts(nil)
+	err = lsRemote(false)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(IsHostKeyError(err)).To(BeFalse())
+	err = lsRemote(true)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(IsHostKeyError(err)).To(BeTrue())
+
+	// The URL is used as written, and has to be an SSH URL.
+	for url, valid := range map[string]bool{
+		"git@gitlab.internal.corp:group/repo.git":            true,
+		"ssh://git@gitlab.internal.corp:2222/group/repo.git": true,
+		"https://gitlab.internal.corp/group/repo.git":        false,
+		"https://dev.azure.com/org/project/_git/repo":        false,
+	} {
+		environment.Spec.Source.URL = url
+		_, cloneURL, err := SetupGitAuthEnvironment(ctx, c, false, environment)
+		if valid {
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cloneURL).To(Equal(url))
+		} else {
+			g.Expect(err).To(HaveOccurred())
+		}
+	}
+}
+
+func TestRepositoryWebURL(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(RepositoryWebURL("git@github.com:org/repo")).To(Equal("https://github.com/org/repo"))
+	g.Expect(RepositoryWebURL("git@gitlab.internal.corp:group/repo.git")).To(Equal("https://gitlab.internal.corp/group/repo"))
+	g.Expect(RepositoryWebURL("ssh://git@gitlab.internal.corp:2222/group/repo.git")).To(Equal("https://gitlab.internal.corp/group/repo"))
+	g.Expect(RepositoryWebURL("https://dev.azure.com/org/project/_git/repo")).To(Equal("https://dev.azure.com/org/project/_git/repo"))
+}
+
+func TestSetupGitAuthEnvironment_HTTPS(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(promotionsv1alpha1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	localURL := newTestRepository(t, map[string]string{"version.yaml": "version: 1.0.0\n"})
+	repoURL := serveTestRepositoryHTTP(t, localURL, func(r *http.Request) bool {
+		if username, password, ok := r.BasicAuth(); ok {
+			return username == "jane" && password == "secret"
+		}
+		return r.Header.Get("Authorization") == "Bearer token"
+	})
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "basic", Namespace: "default"},
+			Data:       map[string][]byte{"username": []byte("jane"), "password": []byte("secret")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "default"},
+			Data:       map[string][]byte{"bearerToken": []byte("token")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "wrong", Namespace: "default"},
+			Data:       map[string][]byte{"username": []byte("jane"), "password": []byte("wrong")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "default"},
+			Data:       map[string][]byte{"username": []byte("jane")},
+		},
+	).Build()
+	environment := func(secretName string) *promotionsv1alpha1.Environment {
+		return &promotionsv1alpha1.Environment{
+			ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+			Spec: promotionsv1alpha1.EnvironmentSpec{
+				Source: promotionsv1alpha1.Source{
+					URL:       repoURL,
+					SecretRef: &corev1.LocalObjectReference{Name: secretName},
+				},
+			},
+		}
+	}
+
+	for _, secretName := range []string{"basic", "token"} {
+		obj := environment(secretName)
+
+		// The URL is used as written.
+		auth, cloneURL, err := SetupGitAuthEnvironment(ctx, c, false, obj)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(cloneURL).To(Equal(repoURL))
+
+		head, err := GitLsRemoteEnvironment(ctx, c, false, obj)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		repo, err := GitCloneEnvironment(ctx, c, nil, false, obj, t.TempDir())
+		g.Expect(err).ToNot(HaveOccurred())
+		ref, err := repo.Head()
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(ref.Hash()).To(Equal(head.Hash()))
+
+		// Pushes are authenticated too.
+		wt, err := repo.Worktree()
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(billyutil.WriteFile(wt.Filesystem, "version.yaml", []byte("version: "+secretName+"\n"), 0644)).To(Succeed())
+		_, err = wt.Add("version.yaml")
+		g.Expect(err).ToNot(HaveOccurred())
+		_, err = wt.Commit("update", &gogit.CommitOptions{
+			Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(repo.Push(&gogit.PushOptions{Auth: auth})).To(Succeed())
+		g.Expect(readTestRepositoryFile(t, localURL, "master", "version.yaml")).To(Equal("version: " + secretName + "\n"))
+	}
+
+	// Wrong credentials are rejected by the server.
+	_, err := GitLsRemoteEnvironment(ctx, c, false, environment("wrong"))
+	g.Expect(err).To(HaveOccurred())
+
+	// A secret without credentials is an error.
+	_, _, err = SetupGitAuthEnvironment(ctx, c, false, environment("empty"))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestGitHubApp(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(promotionsv1alpha1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	// Stub the endpoint creating installation access tokens of the app.
+	var minted atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" || !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		minted.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(githubapp.Token{Token: "ghs_test", ExpiresAt: time.Now().Add(time.Hour)})
+	}))
+	t.Cleanup(tokenServer.Close)
+
+	repoURL := serveTestRepositoryHTTP(t, newTestRepository(t, map[string]string{"version.yaml": "version: 1.0.0\n"}), func(r *http.Request) bool {
+		username, password, ok := r.BasicAuth()
+		return ok && username == "x-access-token" && password == "ghs_test"
+	})
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github-app", Namespace: "default"},
+		Data: map[string][]byte{
+			"githubAppID":             []byte("7"),
+			"githubAppInstallationID": []byte("42"),
+			"githubAppPrivateKey":     pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+			"githubAppBaseURL":        []byte(tokenServer.URL),
+		},
+	}
+	environment := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.EnvironmentSpec{
+			Source: promotionsv1alpha1.Source{
+				URL:       repoURL,
+				SecretRef: &corev1.LocalObjectReference{Name: "github-app"},
+			},
+			ApiTokenSecretRef: &corev1.LocalObjectReference{Name: "github-app"},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+
+	// The installation access token is used for the API, and for the git transport.
+	token, err := GetApiToken(ctx, c, environment)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token).To(Equal("ghs_test"))
+
+	_, err = GitLsRemoteEnvironment(ctx, c, false, environment)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = GitCloneEnvironment(ctx, c, nil, false, environment, t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// The token is minted once, and cached afterwards.
+	g.Expect(minted.Load()).To(Equal(int32(1)))
+
+	// A secret with an incomplete GitHub App is an error.
+	delete(secret.Data, "githubAppInstallationID")
+	_, err = GitHubApp(secret)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestEnvironmentReconciler_Ref(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(promotionsv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	url := newTestRepository(t, map[string]string{"version.yaml": "version: 1.0.0\n"})
+	initial := headTestRepositoryCommit(t, url, "master").Hash.String()
+	commits := map[string]string{}
+	for _, version := range []string{"1.1.0", "1.2.0", "2.0.0", "2.1.0-rc.1"} {
+		commits[version] = commitTestRepository(t, url, map[string]string{"version.yaml": "version: " + version + "\n"})
+		tagTestRepository(t, url, "v"+version, commits[version])
+	}
+	commitTestRepository(t, url, map[string]string{"version.yaml": "version: unreleased\n"})
+
+	tests := []struct {
+		name       string
+		ref        promotionsv1alpha1.GitRepositoryRef
+		wantRef    string
+		wantCommit string
+		wantErr    string
+	}{
+		{
+			name:       "semver",
+			ref:        promotionsv1alpha1.GitRepositoryRef{SemVer: ">=1.0.0 <2.0.0"},
+			wantRef:    "refs/tags/v1.2.0",
+			wantCommit: commits["1.2.0"],
+		},
+		{
+			name:       "semver takes precedence over tag",
+			ref:        promotionsv1alpha1.GitRepositoryRef{SemVer: "^2", Tag: "v1.1.0"},
+			wantRef:    "refs/tags/v2.0.0",
+			wantCommit: commits["2.0.0"],
+		},
+		{
+			name:       "tag",
+			ref:        promotionsv1alpha1.GitRepositoryRef{Tag: "v1.1.0"},
+			wantRef:    "refs/tags/v1.1.0",
+			wantCommit: commits["1.1.0"],
+		},
+		{
+			name:       "commit",
+			ref:        promotionsv1alpha1.GitRepositoryRef{Branch: "master", Commit: initial},
+			wantRef:    "refs/heads/master",
+			wantCommit: initial,
+		},
+		{
+			name:    "no tag in semver range",
+			ref:     promotionsv1alpha1.GitRepositoryRef{SemVer: ">=3.0.0"},
+			wantErr: "no tag in semver range",
+		},
+		{
+			name:    "unknown tag",
+			ref:     promotionsv1alpha1.GitRepositoryRef{Tag: "v0.1.0"},
+			wantErr: `tag "v0.1.0" not found`,
+		},
+		{
+			name:    "unknown commit",
+			ref:     promotionsv1alpha1.GitRepositoryRef{Commit: "0123456789abcdef0123456789abcdef01234567"},
+			wantErr: "failed to check out commit",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ref := tt.ref
+			environment := &promotionsv1alpha1.Environment{
+				ObjectMeta: metav1.ObjectMeta{Name: "staging", Namespace: "default"},
+				Spec: promotionsv1alpha1.EnvironmentSpec{
+					Source: promotionsv1alpha1.Source{URL: url, Reference: &ref},
+				},
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(environment).Build()
+			r := &EnvironmentReconciler{Client: c, Scheme: scheme}
+
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(environment)})
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+
+			// The resolved ref is reported next to the commit.
+			obj := &promotionsv1alpha1.Environment{}
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(environment), obj)).To(Succeed())
+			g.Expect(obj.IsReady()).To(BeTrue())
+			g.Expect(obj.Status.ObservedRef).To(Equal(tt.wantRef))
+			g.Expect(obj.Status.ObservedCommitHash).To(Equal(tt.wantCommit))
+
+			// The commit is checked out as source.
+			latestRef, err := GitLsRemoteEnvironment(ctx, c, false, obj)
+			g.Expect(err).ToNot(HaveOccurred())
+			repo, err := GitCloneEnvironmentRef(ctx, c, nil, false, obj, latestRef, t.TempDir())
+			g.Expect(err).ToNot(HaveOccurred())
+			head, err := repo.Head()
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(head.Hash().String()).To(Equal(tt.wantCommit))
+		})
+	}
+}
+
+func TestResolveReference_PeeledTag(t *testing.T) {
+	g := NewWithT(t)
+
+	tagObject := plumbing.NewHash("1111111111111111111111111111111111111111")
+	commit := plumbing.NewHash("2222222222222222222222222222222222222222")
+	advRefs := packp.NewAdvRefs()
+	advRefs.References["refs/tags/v1.0.0"] = tagObject
+	advRefs.Peeled["refs/tags/v1.0.0"] = commit
+
+	// Annotated tags resolve to the commit they point to.
+	environment := &promotionsv1alpha1.Environment{
+		Spec: promotionsv1alpha1.EnvironmentSpec{
+			Source: promotionsv1alpha1.Source{Reference: &promotionsv1alpha1.GitRepositoryRef{SemVer: "~1.0"}},
+		},
+	}
+	ref, err := ResolveReference(environment, advRefs)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ref.Name()).To(Equal(plumbing.NewTagReferenceName("v1.0.0")))
+	g.Expect(ref.Hash()).To(Equal(commit))
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+// EnvironmentValidator validates Environments.
+type EnvironmentValidator struct{}
+
+// SetupWebhookWithManager sets up the validating webhook with the Manager.
+func (v *EnvironmentValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&promotionsv1alpha1.Environment{}).
+		WithValidator(v).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-promotions-gitopsprom-io-v1alpha1-environment,mutating=false,failurePolicy=fail,sideEffects=None,groups=promotions.gitopsprom.io,resources=environments,verbs=create;update,versions=v1alpha1,name=venvironment.kb.io,admissionReviewVersions=v1
+
+var _ admission.CustomValidator = &EnvironmentValidator{}
+
+// ValidateCreate implements admission.CustomValidator so a webhook will be registered for the type
+func (v *EnvironmentValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	return v.validate(obj)
+}
+
+// ValidateUpdate implements admission.CustomValidator so a webhook will be registered for the type
+func (v *EnvironmentValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	return v.validate(newObj)
+}
+
+// ValidateDelete implements admission.CustomValidator so a webhook will be registered for the type
+func (v *EnvironmentValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+// validate checks the URL of the repository, the controller refuses to clone
+// Environments with invalid URLs as well.
+func (v *EnvironmentValidator) validate(obj runtime.Object) error {
+	environment, ok := obj.(*promotionsv1alpha1.Environment)
+	if !ok {
+		return fmt.Errorf("expected an Environment but got a %T", obj)
+	}
+	var allErrs field.ErrorList
+	if err := ValidateSourceURL(environment.Spec.Source.URL); err != nil {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "source", "url"), environment.Spec.Source.URL, err.Error()))
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(promotionsv1alpha1.GroupVersion.WithKind("Environment").GroupKind(), environment.Name, allErrs)
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+func TestEnvironmentValidator(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	v := &EnvironmentValidator{}
+	environment := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev"},
+		Spec: promotionsv1alpha1.EnvironmentSpec{
+			Source: promotionsv1alpha1.Source{URL: "https://github.com/org/repo"},
+		},
+	}
+	g.Expect(v.ValidateCreate(ctx, environment)).To(Succeed())
+	environment.Spec.Source.URL = "git@github.com:org/repo"
+	g.Expect(v.ValidateCreate(ctx, environment)).To(Succeed())
+
+	// The mirrors of the git cache can't be cloned.
+	for _, url := range []string{"gitcache:///0123abcd", "GitCache:///0123abcd"} {
+		environment.Spec.Source.URL = url
+		err := v.ValidateUpdate(ctx, environment, environment)
+		g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		g.Expect(err.Error()).To(ContainSubstring("spec.source.url"))
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+// FreezeWindowValidator validates FreezeWindows.
+type FreezeWindowValidator struct{}
+
+// SetupWebhookWithManager sets up the validating webhook with the Manager.
+func (v *FreezeWindowValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&promotionsv1alpha1.FreezeWindow{}).
+		WithValidator(v).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-promotions-gitopsprom-io-v1alpha1-freezewindow,mutating=false,failurePolicy=fail,sideEffects=None,groups=promotions.gitopsprom.io,resources=freezewindows,verbs=create;update,versions=v1alpha1,name=vfreezewindow.kb.io,admissionReviewVersions=v1
+
+var _ admission.CustomValidator = &FreezeWindowValidator{}
+
+// ValidateCreate implements admission.CustomValidator so a webhook will be registered for the type
+func (v *FreezeWindowValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	return v.validate(obj)
+}
+
+// ValidateUpdate implements admission.CustomValidator so a webhook will be registered for the type
+func (v *FreezeWindowValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	return v.validate(newObj)
+}
+
+// ValidateDelete implements admission.CustomValidator so a webhook will be registered for the type
+func (v *FreezeWindowValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+// validate checks the windows and the selector, as an invalid FreezeWindow
+// blocks all Promotions.
+func (v *FreezeWindowValidator) validate(obj runtime.Object) error {
+	freeze, ok := obj.(*promotionsv1alpha1.FreezeWindow)
+	if !ok {
+		return fmt.Errorf("expected a FreezeWindow but got a %T", obj)
+	}
+	allErrs := validateScheduleWindows(field.NewPath("spec", "windows"), freeze.Spec.Windows, freeze.Spec.TimeZone)
+	if freeze.Spec.Selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(freeze.Spec.Selector); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "selector"), freeze.Spec.Selector, err.Error()))
+		}
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(promotionsv1alpha1.GroupVersion.WithKind("FreezeWindow").GroupKind(), freeze.Name, allErrs)
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+func TestFreezeWindowValidator(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	v := &FreezeWindowValidator{}
+	freeze := &promotionsv1alpha1.FreezeWindow{
+		ObjectMeta: metav1.ObjectMeta{Name: "december"},
+		Spec: promotionsv1alpha1.FreezeWindowSpec{
+			Windows:  []promotionsv1alpha1.ScheduleWindow{{Cron: "0 0 1 DEC *", Duration: metav1.Duration{Duration: 31 * 24 * time.Hour}}},
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "prod"}},
+		},
+	}
+	g.Expect(v.ValidateCreate(ctx, freeze)).To(Succeed())
+
+	freeze.Spec.Windows[0].Duration = metav1.Duration{}
+	freeze.Spec.Selector.MatchExpressions = []metav1.LabelSelectorRequirement{{Key: "tier", Operator: "Maybe"}}
+	err := v.ValidateUpdate(ctx, freeze, freeze)
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(err.Error()).To(And(ContainSubstring("spec.windows[0]"), ContainSubstring("spec.selector")))
+}
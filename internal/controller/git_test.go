@@ -0,0 +1,305 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func init() {
+	// Serve file:// URLs in-process, so the tests don't depend on a git binary.
+	client.InstallProtocol("file", server.DefaultServer)
+}
+
+// newTestRepository creates a bare repository with a single commit on the
+// "master" branch containing the given files, and returns its URL.
+func newTestRepository(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := gogit.Init(filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault()), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range files {
+		if err := billyutil.WriteFile(wt.Filesystem, path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := wt.Commit("initial commit", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return "file://" + dir
+}
+
+// readTestRepositoryFile returns the content of the file at path on the given
+// branch of the repository at url.
+func readTestRepositoryFile(t *testing.T, url, branch, path string) string {
+	t.Helper()
+
+	repo, err := gogit.Clone(memory.NewStorage(), memfs.New(), &gogit.CloneOptions{
+		URL:           url,
+		ReferenceName: plumbing.NewBranchReferenceName(branch),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := billyutil.ReadFile(wt.Filesystem, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// headTestRepositoryCommit returns the head commit of the given branch of the
+// repository at url.
+func headTestRepositoryCommit(t *testing.T, url, branch string) *object.Commit {
+	t.Helper()
+
+	repo, err := gogit.Clone(memory.NewStorage(), nil, &gogit.CloneOptions{
+		URL:           url,
+		ReferenceName: plumbing.NewBranchReferenceName(branch),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return commit
+}
+
+// listTestRepositoryFiles returns the paths of all files on the given branch
+// of the repository at url.
+func listTestRepositoryFiles(t *testing.T, url, branch string) []string {
+	t.Helper()
+
+	repo, err := gogit.Clone(memory.NewStorage(), nil, &gogit.CloneOptions{
+		URL:           url,
+		ReferenceName: plumbing.NewBranchReferenceName(branch),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := commit.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	if err := files.ForEach(func(f *object.File) error {
+		paths = append(paths, f.Name)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+// commitTestRepository pushes a commit with the given files to the "master"
+// branch of the repository at url, and returns the hash of the commit.
+func commitTestRepository(t *testing.T, url string, files map[string]string) string {
+	t.Helper()
+
+	repo, err := gogit.Clone(memory.NewStorage(), memfs.New(), &gogit.CloneOptions{
+		URL:           url,
+		ReferenceName: plumbing.NewBranchReferenceName("master"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range files {
+		if err := billyutil.WriteFile(wt.Filesystem, path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash, err := wt.Commit("update", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Push(&gogit.PushOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	return hash.String()
+}
+
+// serveTestRepositoryHTTP serves the repository at the file:// URL over the
+// smart HTTP protocol of git, and returns its http:// URL. Requests are
+// rejected with 401 Unauthorized, unless authorized returns true for them.
+func serveTestRepositoryHTTP(t *testing.T, url string, authorized func(r *http.Request) bool) string {
+	t.Helper()
+
+	endpoint := &transport.Endpoint{Protocol: "file", Path: strings.TrimPrefix(url, "file://")}
+	handle := func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/info/refs"):
+			service := r.URL.Query().Get("service")
+			var session interface {
+				AdvertisedReferencesContext(ctx context.Context) (*packp.AdvRefs, error)
+			}
+			var err error
+			if service == transport.ReceivePackServiceName {
+				session, err = server.DefaultServer.NewReceivePackSession(endpoint, nil)
+			} else {
+				session, err = server.DefaultServer.NewUploadPackSession(endpoint, nil)
+			}
+			if err != nil {
+				return err
+			}
+			refs, err := session.AdvertisedReferencesContext(ctx)
+			if err != nil {
+				return err
+			}
+			refs.Prefix = [][]byte{[]byte("# service=" + service), pktline.Flush}
+			w.Header().Set("Content-Type", "application/x-"+service+"-advertisement")
+			return refs.Encode(w)
+
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/"+transport.UploadPackServiceName):
+			req := packp.NewUploadPackRequest()
+			if err := req.UploadRequest.Decode(r.Body); err != nil {
+				return err
+			}
+			// The wants are followed by the haves, and "done".
+			scanner := pktline.NewScanner(r.Body)
+			for scanner.Scan() {
+				line := strings.TrimSpace(string(scanner.Bytes()))
+				if strings.HasPrefix(line, "have ") {
+					req.Haves = append(req.Haves, plumbing.NewHash(strings.TrimPrefix(line, "have ")))
+				}
+			}
+			session, err := server.DefaultServer.NewUploadPackSession(endpoint, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := session.UploadPack(ctx, req)
+			if err != nil {
+				return err
+			}
+			w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
+			return resp.Encode(w)
+
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/"+transport.ReceivePackServiceName):
+			req := packp.NewReferenceUpdateRequest()
+			if err := req.Decode(r.Body); err != nil {
+				return err
+			}
+			session, err := server.DefaultServer.NewReceivePackSession(endpoint, nil)
+			if err != nil {
+				return err
+			}
+			status, err := session.ReceivePack(ctx, req)
+			if status == nil {
+				return err
+			}
+			w.Header().Set("Content-Type", "application/x-git-receive-pack-result")
+			return status.Encode(w)
+		}
+		http.NotFound(w, r)
+		return nil
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := handle(w, r); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/repo.git"
+}
+
+// tagTestRepository pushes a lightweight tag of the commit with the given hash
+// to the repository at url.
+func tagTestRepository(t *testing.T, url, tag, hash string) {
+	t.Helper()
+
+	repo, err := gogit.Clone(memory.NewStorage(), nil, &gogit.CloneOptions{
+		URL:           url,
+		ReferenceName: plumbing.NewBranchReferenceName("master"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateTag(tag, plumbing.NewHash(hash), nil); err != nil {
+		t.Fatal(err)
+	}
+	refSpec := config.RefSpec("refs/tags/" + tag + ":refs/tags/" + tag)
+	if err := repo.Push(&gogit.PushOptions{RefSpecs: []config.RefSpec{refSpec}}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -22,38 +22,83 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
-	"text/template"
+	"sort"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	"github.com/fluxcd/go-git-providers/gitprovider"
+	"github.com/bmatcuk/doublestar/v4"
 	gogit "github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/storer"
 
-	securejoin "github.com/cyphar/filepath-securejoin"
 	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
 	"github.com/thomasstxyz/gitops-promotions-operator/internal/fs"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/gitcache"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/kustomize"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/signing"
 	"github.com/thomasstxyz/gitops-promotions-operator/internal/util"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/yamlpath"
+)
+
+const (
+	// SourceEnvironmentRefIndexKey and TargetEnvironmentRefIndexKey are the
+	// keys of the field indexes of Promotions by their environment references.
+	SourceEnvironmentRefIndexKey = ".spec.sourceEnvironmentRef.name"
+	TargetEnvironmentRefIndexKey = ".spec.targetEnvironmentRef.name"
+
+	// DefaultPromotionRequeueInterval is the default interval at which Promotions
+	// are reconciled, in case a change of the source environment was missed.
+	DefaultPromotionRequeueInterval = 300 * time.Second
+	// DefaultEnvironmentNotReadyRequeueInterval is the default interval at which
+	// Promotions are reconciled while waiting for their environments to get ready.
+	DefaultEnvironmentNotReadyRequeueInterval = 10 * time.Second
 )
 
 // PromotionReconciler reconciles a Promotion object
 type PromotionReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// RequeueInterval defaults to DefaultPromotionRequeueInterval.
+	RequeueInterval time.Duration
+	// NotReadyRequeueInterval defaults to DefaultEnvironmentNotReadyRequeueInterval.
+	NotReadyRequeueInterval time.Duration
+
+	// GitCache is the cache the repositories are cloned from.
+	// Repositories are cloned from their remote if nil.
+	GitCache *gitcache.Cache
+
+	// RequireKnownHosts refuses to connect to SSH servers whose host key
+	// can't be verified against the known hosts of the secret of the Environment.
+	RequireKnownHosts bool
+
+	// WebhooksDisabled is set if the admission webhooks are not served.
+	// PromotionApprovals are ignored then, as anyone allowed to create them
+	// could set their approver.
+	WebhooksDisabled bool
 }
 
 //+kubebuilder:rbac:groups=promotions.gitopsprom.io,resources=promotions,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=promotions.gitopsprom.io,resources=promotions/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=promotions.gitopsprom.io,resources=promotions/finalizers,verbs=update
+//+kubebuilder:rbac:groups=promotions.gitopsprom.io,resources=promotionapprovals,verbs=get;list;watch
+//+kubebuilder:rbac:groups=promotions.gitopsprom.io,resources=freezewindows,verbs=get;list;watch
 
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 
@@ -68,6 +113,18 @@ func (r *PromotionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	if !obj.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, obj)
+	}
+
+	// Add the finalizer before anything is promoted, so that the pull request can be cleaned up.
+	if !controllerutil.ContainsFinalizer(obj, promotionsv1alpha1.PromotionFinalizer) {
+		controllerutil.AddFinalizer(obj, promotionsv1alpha1.PromotionFinalizer)
+		if err := r.Update(ctx, obj); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Run these functions after the reconcile loop
 	defer func() {
 		obj.Status.ObservedGeneration = obj.GetObjectMeta().GetGeneration()
@@ -77,6 +134,11 @@ func (r *PromotionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 	}()
 
+	if obj.Spec.Suspend {
+		log.Info("Promotion is suspended, skipping reconciliation")
+		return ctrl.Result{}, nil
+	}
+
 	// Get source and target environments
 	sourceEnvironment := &promotionsv1alpha1.Environment{}
 	if err := r.Get(ctx, types.NamespacedName{Namespace: obj.Namespace, Name: obj.Spec.SourceEnvironmentRef.Name}, sourceEnvironment); err != nil {
@@ -89,25 +151,29 @@ func (r *PromotionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 	// Ensure that the source and target environments are ready
 	if !sourceEnvironment.IsReady() {
-		log.Info("Waiting for source environment to get ready", "sourceEnvironment", sourceEnvironment, "requeueAfter", "10s")
+		log.Info("Waiting for source environment to get ready", "sourceEnvironment", sourceEnvironment, "requeueAfter", r.notReadyRequeueInterval())
 		return ctrl.Result{
-			RequeueAfter: 10 * time.Second,
+			RequeueAfter: r.notReadyRequeueInterval(),
 		}, nil
 	}
 	if !targetEnvironment.IsReady() {
-		log.Info("Waiting for target environment to get ready", "targetEnvironment", targetEnvironment, "requeueAfter", "10s")
+		log.Info("Waiting for target environment to get ready", "targetEnvironment", targetEnvironment, "requeueAfter", r.notReadyRequeueInterval())
 		return ctrl.Result{
-			RequeueAfter: 10 * time.Second,
+			RequeueAfter: r.notReadyRequeueInterval(),
 		}, nil
 	}
 
-	// Clone source environment repo
+	// Clone source environment repo at its branch, tag, semver range or commit
+	sourceEnvironmentRef, err := GitLsRemoteEnvironment(ctx, r.Client, r.RequireKnownHosts, sourceEnvironment)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 	tmpDir, err := util.TempDirForObj("", obj)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 	defer os.RemoveAll(tmpDir)
-	sourceEnvironmentRepo, err := GitCloneEnvironment(ctx, r.Client, sourceEnvironment, tmpDir)
+	sourceEnvironmentRepo, err := GitCloneEnvironmentRef(ctx, r.Client, r.GitCache, r.RequireKnownHosts, sourceEnvironment, sourceEnvironmentRef, tmpDir)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
@@ -119,14 +185,13 @@ func (r *PromotionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, err
 	}
 	defer os.RemoveAll(tmpDir)
-	targetEnvironmentRepo, err := GitCloneEnvironment(ctx, r.Client, targetEnvironment, tmpDir)
+	targetEnvironmentRepo, err := GitCloneEnvironment(ctx, r.Client, r.GitCache, r.RequireKnownHosts, targetEnvironment, tmpDir)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 	targetEnvironmentPath := tmpDir
 
-	// Get the GitProviderRepo for the target environment
-	targetEnvironmentGitProviderRepo, err := NewGitProviderOrgRepository(ctx, r.Client, targetEnvironment, targetEnvironmentRepo)
+	strategy, err := NewPromotionStrategy(r.Client, obj.Spec.Strategy)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
@@ -145,216 +210,212 @@ func (r *PromotionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	if err != nil {
 		return ctrl.Result{}, err
 	}
-	// targetEnvironmentRepoHeadRef, err := targetEnvironmentRepo.Head()
-	// if err != nil {
-	// 	return ctrl.Result{}, err
-	// }
 
 	// Get the source environment's latest git commit
 	sourceEnvironmentLatestCommit, err := sourceEnvironmentRepo.CommitObject(sourceEnvironmentRepoHeadRef.Hash())
 	if err != nil {
 		return ctrl.Result{}, err
 	}
-	// Get the target environment's latest git commit
-	// targetEnvironmentLatestCommit, err := targetEnvironmentRepo.CommitObject(targetEnvironmentRepoHeadRef.Hash())
-	// if err != nil {
-	// 	return ctrl.Result{}, err
-	// }
 
-	gitAuthOpts, cloneURL, err := SetupGitAuthEnvironment(ctx, r.Client, targetEnvironment)
+	obj.Status.ObservedSourceCommitHash = sourceEnvironmentLatestCommit.Hash.String()
+
+	gitAuthOpts, cloneURL, err := SetupGitAuthEnvironment(ctx, r.Client, r.RequireKnownHosts, targetEnvironment)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	prs, err := targetEnvironmentGitProviderRepo.PullRequests().List(ctx)
+	signingKey, err := GetSigningKey(ctx, r.Client, obj, targetEnvironment)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	// isPROpen tells us whether there's already an open pull request for this promotion
-	var isPROpen bool
-	if obj.Status.LastPullRequestNumber != 0 {
-		for _, pr := range prs {
-			if pr.Get().Number == obj.Status.LastPullRequestNumber {
-				isPROpen = true
-				break
-			}
-		}
-	}
-
-	var pr gitprovider.PullRequest
-	var branch string
-	if isPROpen {
-		pr, err = targetEnvironmentGitProviderRepo.PullRequests().Get(ctx, obj.Status.LastPullRequestNumber)
+	var approval *promotionsv1alpha1.PromotionApproval
+	if obj.Spec.Approval != nil && !r.WebhooksDisabled {
+		approval, err = GetPromotionApproval(ctx, r.Client, obj, sourceEnvironmentLatestCommit.Hash.String())
 		if err != nil {
 			return ctrl.Result{}, err
 		}
-
-		branch = pr.Get().SourceBranch
-
-		if err := targetEnvironmentRepo.Fetch(&gogit.FetchOptions{
-			RefSpecs:  []config.RefSpec{"refs/*:refs/*", "HEAD:refs/heads/HEAD"},
-			Auth:      gitAuthOpts,
-			RemoteURL: cloneURL,
-		}); err != nil {
-			return ctrl.Result{}, err
-		}
-
-		if err = targetEnvironmentWorktree.Checkout(&gogit.CheckoutOptions{
-			Branch: plumbing.ReferenceName(fmt.Sprintf("refs/heads/%s", branch)),
-			Force:  true,
-		}); err != nil {
-			return ctrl.Result{}, err
-		}
-	} else if !isPROpen {
-		branch = fmt.Sprintf("promotion/%s-%s", obj.Name, time.Now().Format("2006-01-02-15-04-05"))
-
-		if err := targetEnvironmentWorktree.Checkout(&gogit.CheckoutOptions{
-			Branch: plumbing.NewBranchReferenceName(branch),
-			Create: true,
-		}); err != nil {
-			return ctrl.Result{}, err
-		}
 	}
 
-	var promotedSubjects []string
-
-	beforeHeadRef, err := targetEnvironmentRepo.Head()
+	block, err := GetScheduleBlock(ctx, r.Client, obj, time.Now())
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+	SetScheduleBlock(obj, block)
+
+	run := &PromotionRun{
+		Promotion:                     obj,
+		SourceEnvironment:             sourceEnvironment,
+		TargetEnvironment:             targetEnvironment,
+		SourceEnvironmentRepo:         sourceEnvironmentRepo,
+		TargetEnvironmentRepo:         targetEnvironmentRepo,
+		TargetEnvironmentWorktree:     targetEnvironmentWorktree,
+		SourceEnvironmentPath:         sourceEnvironmentPath,
+		TargetEnvironmentPath:         targetEnvironmentPath,
+		SourceEnvironmentLatestCommit: sourceEnvironmentLatestCommit,
+		TargetGitAuth:                 gitAuthOpts,
+		TargetCloneURL:                cloneURL,
+		SigningKey:                    signingKey,
+		Approval:                      approval,
+		IgnoreApprovals:               r.WebhooksDisabled,
+		Block:                         block,
+	}
+	if err := strategy.Promote(ctx, run); err != nil {
+		return ctrl.Result{}, err
+	}
 
-	// Copy the promotion subjects from the source environment to the target environment
-
-	sourceEnvironmentFullPath := filepath.Join(sourceEnvironmentPath, sourceEnvironment.Spec.Path)
-	targetEnvironmentFullPath := filepath.Join(targetEnvironmentPath, targetEnvironment.Spec.Path)
-
-	for _, copyOperation := range obj.Spec.Copy {
-		copySource, err := securejoin.SecureJoin(sourceEnvironmentFullPath, copyOperation.Source)
-		if err != nil {
-			return ctrl.Result{}, err
+	// Promote right when the schedule allows it again.
+	requeueAfter := r.requeueInterval()
+	if block != nil && !block.Until.IsZero() {
+		if untilAllowed := time.Until(block.Until); untilAllowed < requeueAfter {
+			requeueAfter = untilAllowed
 		}
-		copyTarget, err := securejoin.SecureJoin(targetEnvironmentFullPath, copyOperation.Target)
-		if err != nil {
-			return ctrl.Result{}, err
-		}
-
-		if err := CopyOperation(ctx, obj, copySource, copyTarget); err != nil {
-			return ctrl.Result{}, err
+		if requeueAfter <= 0 {
+			requeueAfter = time.Second
 		}
+	}
 
-		var status gogit.Status
-		status, err = targetEnvironmentWorktree.Status()
-		if err != nil {
-			return ctrl.Result{}, err
-		}
+	end := time.Now()
+	log.Info("Reconciled Promotion successfully", "duration", end.Sub(start), "nextReconcile", requeueAfter)
 
-		if status.IsClean() {
-			// fmt.Println("No changes were made by this copy operation.")
-		} else {
-			// fmt.Println("Changes were made by this copy operation.")
+	return ctrl.Result{
+		RequeueAfter: requeueAfter,
+	}, nil
+}
 
-			// Add all files to the target environment git worktree
-			if err := targetEnvironmentWorktree.AddGlob("."); err != nil {
-				return ctrl.Result{}, err
-			}
+// reconcileDelete cleans up the pull request of the Promotion according to its
+// deletion policy, and removes the finalizer.
+func (r *PromotionReconciler) reconcileDelete(ctx context.Context, obj *promotionsv1alpha1.Promotion) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(obj, promotionsv1alpha1.PromotionFinalizer) {
+		return ctrl.Result{}, nil
+	}
 
-			// Template commit message.
-			type TemplateData struct {
-				Prom                          *promotionsv1alpha1.Promotion
-				SourceEnv                     *promotionsv1alpha1.Environment
-				TargetEnv                     *promotionsv1alpha1.Environment
-				SourceEnvironmentLatestCommit string
-				CopyOperation                 promotionsv1alpha1.CopyOperation
-			}
-			tplData := TemplateData{obj, sourceEnvironment, targetEnvironment, sourceEnvironmentLatestCommit.Hash.String()[0:7], copyOperation}
+	if obj.Spec.DeletionPolicy == promotionsv1alpha1.DeletionPolicyClose {
+		if err := r.closePullRequest(ctx, obj); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
-			tmpl, err := template.New("tpl").Parse(
-				`chore: promote {{.CopyOperation.Name}} from {{.SourceEnv.Name}} to {{.TargetEnv.Name}}
+	controllerutil.RemoveFinalizer(obj, promotionsv1alpha1.PromotionFinalizer)
+	return ctrl.Result{}, r.Update(ctx, obj)
+}
 
-SHA in source environment: {{.SourceEnvironmentLatestCommit}}
-`)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-			var tpl bytes.Buffer
-			err = tmpl.Execute(&tpl, tplData)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-			commitMsg := tpl.String()
-
-			_, err = targetEnvironmentWorktree.Commit(commitMsg,
-				&gogit.CommitOptions{
-					Author: &object.Signature{
-						Name:  "Promotion Bot",
-						Email: "bot@promotions.gitopsprom.io",
-						When:  time.Now(),
-					},
-				})
-			if err != nil {
-				return ctrl.Result{}, err
-			}
+// closePullRequest closes the open pull request of the Promotion with a comment,
+// and deletes its branch. The pull request is left behind if the target
+// environment, or one of its secrets, no longer exists.
+func (r *PromotionReconciler) closePullRequest(ctx context.Context, obj *promotionsv1alpha1.Promotion) error {
+	log := log.FromContext(ctx)
 
-			if err := targetEnvironmentRepo.Push(&gogit.PushOptions{
-				RemoteName: "origin",
-				RemoteURL:  cloneURL,
-				Auth:       gitAuthOpts,
-			}); err != nil {
-				return ctrl.Result{}, err
-			}
+	if obj.Status.LastPullRequestNumber == 0 || obj.Status.LastPullRequestState == promotionsv1alpha1.PullRequestStateMerged ||
+		obj.Status.LastPullRequestState == promotionsv1alpha1.PullRequestStateClosed {
+		return nil
+	}
 
-			promotedSubjects = append(promotedSubjects, copyOperation.Name)
+	targetEnvironment := &promotionsv1alpha1.Environment{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: obj.Namespace, Name: obj.Spec.TargetEnvironmentRef.Name}, targetEnvironment); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("Target environment not found, leaving pull request behind", "number", obj.Status.LastPullRequestNumber)
+			return nil
+		}
+		return err
+	}
 
-			*obj = promotionsv1alpha1.PromotionReady(*obj, promotionsv1alpha1.SucceededReason, "Pushed new commits to PR branch")
+	targetEnvironmentProvider, err := NewPullRequestProvider(ctx, r.Client, targetEnvironment, nil)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("API token secret of target environment not found, leaving pull request behind", "number", obj.Status.LastPullRequestNumber)
+			return nil
 		}
+		return err
+	}
+	pr, err := targetEnvironmentProvider.GetPullRequest(ctx, obj.Status.LastPullRequestNumber)
+	if err != nil {
+		return err
+	}
+	if pr.Open {
+		comment := fmt.Sprintf("Closed, as the promotion %s was deleted.", obj.Name)
+		if err := targetEnvironmentProvider.ClosePullRequest(ctx, pr.Number, comment); err != nil {
+			return err
+		}
+		log.Info("Closed pull request of deleted promotion", "WebURL", pr.WebURL)
 	}
 
-	afterHeadRef, err := targetEnvironmentRepo.Head()
+	gitAuthOpts, cloneURL, err := SetupGitAuthEnvironment(ctx, r.Client, r.RequireKnownHosts, targetEnvironment)
 	if err != nil {
-		return ctrl.Result{}, err
+		if apierrors.IsNotFound(err) {
+			log.Info("Git secret of target environment not found, leaving branch behind", "branch", pr.SourceBranch)
+			return nil
+		}
+		return err
 	}
+	return DeleteRemoteBranch(ctx, cloneURL, gitAuthOpts, pr.SourceBranch)
+}
 
-	// If we introduced new commits
-	if beforeHeadRef.Hash().String() != afterHeadRef.Hash().String() {
-		promotedSubjectsFormatted := strings.Join(promotedSubjects, ", ")
-		prTitle := fmt.Sprintf("chore: promote %s from %s to %s", promotedSubjectsFormatted, sourceEnvironment.Name, targetEnvironment.Name)
+func (r *PromotionReconciler) requeueInterval() time.Duration {
+	if r.RequeueInterval > 0 {
+		return r.RequeueInterval
+	}
+	return DefaultPromotionRequeueInterval
+}
 
-		if isPROpen {
-			_, err = targetEnvironmentGitProviderRepo.PullRequests().Edit(ctx, pr.Get().Number, gitprovider.EditOptions{
-				Title: &prTitle,
-			})
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-		} else {
-			pr, err = targetEnvironmentGitProviderRepo.PullRequests().Create(ctx, prTitle, branch, targetEnvironment.Spec.Source.Reference.Branch, "")
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-			isPROpen = true
+func (r *PromotionReconciler) notReadyRequeueInterval() time.Duration {
+	if r.NotReadyRequeueInterval > 0 {
+		return r.NotReadyRequeueInterval
+	}
+	return DefaultEnvironmentNotReadyRequeueInterval
+}
 
-			log.Info("Created new pull request", "WebURL", pr.Get().WebURL)
-			*obj = promotionsv1alpha1.PromotionReady(*obj, promotionsv1alpha1.SucceededReason, "New Pull request created successfully")
+// GetSigningKey returns the key the commits of the Promotion are signed with,
+// from the secret referenced by the SigningKeySecretRef of the Promotion, or
+// else of the target Environment. It returns nil if there is no such reference.
+func GetSigningKey(ctx context.Context, client client.Client, obj *promotionsv1alpha1.Promotion, target *promotionsv1alpha1.Environment) (*signing.Key, error) {
+	secretRef := target.Spec.SigningKeySecretRef
+	namespace := target.Namespace
+	if obj.Spec.SigningKeySecretRef != nil {
+		secretRef = obj.Spec.SigningKeySecretRef
+		namespace = obj.Namespace
+	}
+	if secretRef == nil {
+		return nil, nil
+	}
 
-			obj.Status.LastPullRequestNumber = pr.Get().Number
-			obj.Status.LastPullRequestURL = pr.Get().WebURL
-		}
-	} else {
-		*obj = promotionsv1alpha1.PromotionReady(*obj, promotionsv1alpha1.SucceededReason, "A pull request is open for review.")
+	secret := &corev1.Secret{}
+	if err := client.Get(ctx, types.NamespacedName{Name: secretRef.Name, Namespace: namespace}, secret); err != nil {
+		return nil, err
 	}
+	return signing.ParseKey(secret.Data["signingKey"], secret.Data["passphrase"])
+}
 
-	// If there's no open PR at this point, we assume that the source and target environments are in sync.
-	if !isPROpen {
-		*obj = promotionsv1alpha1.PromotionReady(*obj, promotionsv1alpha1.SucceededReason, "Source and target environments are in sync, nothing to promote.")
+// GetPromotionApproval returns the PromotionApproval of the Promotion which
+// approves sourceCommit, and was created by one of the approvers of its
+// approval policy. Approvals of other commits are ignored, so that every new
+// commit of the source environment requires a new approval.
+// It returns nil if there is no such approval.
+func GetPromotionApproval(ctx context.Context, c client.Client, obj *promotionsv1alpha1.Promotion, sourceCommit string) (*promotionsv1alpha1.PromotionApproval, error) {
+	approvals := &promotionsv1alpha1.PromotionApprovalList{}
+	if err := c.List(ctx, approvals, client.InNamespace(obj.Namespace)); err != nil {
+		return nil, err
 	}
 
-	end := time.Now()
-	log.Info("Reconciled Promotion successfully", "duration", end.Sub(start), "nextReconcile", "300s")
+	var accepted []promotionsv1alpha1.PromotionApproval
+	for _, approval := range approvals.Items {
+		if approval.Spec.PromotionRef.Name == obj.Name && approval.Spec.SourceCommitHash == sourceCommit &&
+			obj.Spec.Approval.Accepts(approval.Spec.ApprovedBy) {
+			accepted = append(accepted, approval)
+		}
+	}
+	if len(accepted) == 0 {
+		return nil, nil
+	}
 
-	return ctrl.Result{
-		RequeueAfter: 300 * time.Second,
-	}, nil
+	// The first approval is recorded, if a commit was approved more than once.
+	sort.Slice(accepted, func(i, j int) bool {
+		if !accepted[i].CreationTimestamp.Equal(&accepted[j].CreationTimestamp) {
+			return accepted[i].CreationTimestamp.Before(&accepted[j].CreationTimestamp)
+		}
+		return accepted[i].Name < accepted[j].Name
+	})
+	return &accepted[0], nil
 }
 
 // GetCommitObject returns the commit object for a given commit hash
@@ -369,36 +430,251 @@ func GetCommitObject(ctx context.Context, client client.Client, obj *promotionsv
 	return commit, nil
 }
 
-func CopyOperation(ctx context.Context, obj *promotionsv1alpha1.Promotion,
+// CopyOperation performs the copy operation from copySource to copyTarget.
+func CopyOperation(ctx context.Context, op promotionsv1alpha1.CopyOperation,
 	copySource string, copyTarget string) error {
 
 	if !fs.Exists(copySource) {
 		return fmt.Errorf("source path %s does not exist", copySource)
 	}
 
+	switch op.GetType() {
+	case promotionsv1alpha1.CopyOperationTypeYAMLPath:
+		return CopyYAMLPath(op, copySource, copyTarget)
+	case promotionsv1alpha1.CopyOperationTypeKustomizeImages:
+		return CopyKustomizeImages(op, copySource, copyTarget)
+	case promotionsv1alpha1.CopyOperationTypeHelmValues:
+		return CopyHelmValues(op, copySource, copyTarget)
+	default:
+		filter, err := CopyFilter(op)
+		if err != nil {
+			return err
+		}
+		if err := CopyFiles(copySource, copyTarget, filter); err != nil {
+			return err
+		}
+		if op.Prune {
+			return PruneFiles(op, copySource, copyTarget)
+		}
+		return nil
+	}
+}
+
+// CopyFilter returns a function which tells whether a file is selected by the
+// include and exclude patterns of the copy operation, given its slash-separated
+// path relative to the source or target directory. It returns a nil function
+// if the copy operation has no patterns.
+func CopyFilter(op promotionsv1alpha1.CopyOperation) (func(path string) bool, error) {
+	if len(op.Include) == 0 && len(op.Exclude) == 0 {
+		return nil, nil
+	}
+	for _, pattern := range append(append([]string{}, op.Include...), op.Exclude...) {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("copy operation %q: invalid pattern %q", op.Name, pattern)
+		}
+	}
+
+	return func(path string) bool {
+		// The patterns are validated above.
+		included := len(op.Include) == 0
+		for _, pattern := range op.Include {
+			if ok, _ := doublestar.Match(pattern, path); ok {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+		for _, pattern := range op.Exclude {
+			if ok, _ := doublestar.Match(pattern, path); ok {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// CopyTargetPath returns the path of the file or directory which the copy
+// operation writes to.
+func CopyTargetPath(op promotionsv1alpha1.CopyOperation, copySource string, copyTarget string) string {
+	switch op.GetType() {
+	case promotionsv1alpha1.CopyOperationTypeKustomizeImages:
+		if file, err := kustomize.FindKustomization(copyTarget); err == nil {
+			return file
+		}
+	case promotionsv1alpha1.CopyOperationTypeHelmValues:
+		if fs.IsDir(copyTarget) {
+			return filepath.Join(copyTarget, "values.yaml")
+		}
+	case promotionsv1alpha1.CopyOperationTypeCopy:
+		if !fs.IsDir(copySource) && fs.IsDir(copyTarget) {
+			return filepath.Join(copyTarget, filepath.Base(copySource))
+		}
+	}
+	return copyTarget
+}
+
+// PruneFiles deletes the files in the copyTarget directory which are not in
+// the copySource directory, except for those matching the protect patterns
+// of the copy operation.
+func PruneFiles(op promotionsv1alpha1.CopyOperation, copySource string, copyTarget string) error {
+	if !fs.IsDir(copySource) {
+		return fmt.Errorf("copy operation %q: prune requires the source to be a directory", op.Name)
+	}
+	for _, pattern := range op.Protect {
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf("copy operation %q: invalid protect pattern %q", op.Name, pattern)
+		}
+	}
+	filter, err := CopyFilter(op)
+	if err != nil {
+		return err
+	}
+
+	return fs.Prune(copySource, copyTarget, func(path string, isDir bool) bool {
+		// Files which are not selected by the copy operation are not managed by it.
+		// The patterns select files, so directories are descended into.
+		if !isDir && filter != nil && !filter(path) {
+			return true
+		}
+		for _, pattern := range op.Protect {
+			// The patterns are validated above.
+			if ok, _ := doublestar.Match(pattern, path); ok {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// CopyYAMLPath copies the value at the YAML path of the copy operation
+// from the copySource file into the copyTarget file, which is created
+// if it does not exist.
+func CopyYAMLPath(op promotionsv1alpha1.CopyOperation, copySource string, copyTarget string) error {
+	if op.YAMLPath == "" {
+		return fmt.Errorf("copy operation %q of type %s requires yamlPath", op.Name, op.GetType())
+	}
+
+	return editFile(copySource, copyTarget, true, func(source, target []byte) ([]byte, error) {
+		out, err := yamlpath.Copy(source, op.YAMLPath, target, op.GetTargetYAMLPath())
+		if err != nil {
+			return nil, fmt.Errorf("copy operation %q: %w", op.Name, err)
+		}
+		return out, nil
+	})
+}
+
+// CopyKustomizeImages merges the images of the copy operation from the
+// kustomization at copySource into the kustomization at copyTarget.
+func CopyKustomizeImages(op promotionsv1alpha1.CopyOperation, copySource string, copyTarget string) error {
+	sourceFile, err := kustomize.FindKustomization(copySource)
+	if err != nil {
+		return err
+	}
+	targetFile, err := kustomize.FindKustomization(copyTarget)
+	if err != nil {
+		return err
+	}
+
+	return editFile(sourceFile, targetFile, false, func(source, target []byte) ([]byte, error) {
+		out, err := kustomize.MergeImages(source, target, op.Images)
+		if err != nil {
+			return nil, fmt.Errorf("copy operation %q: %w", op.Name, err)
+		}
+		return out, nil
+	})
+}
+
+// CopyHelmValues merges the keys of the copy operation from the Helm values
+// file at copySource into the values file at copyTarget. Source and target
+// may also refer to the directory containing the values.yaml file.
+func CopyHelmValues(op promotionsv1alpha1.CopyOperation, copySource string, copyTarget string) error {
+	if len(op.Keys) == 0 {
+		return fmt.Errorf("copy operation %q of type %s requires keys", op.Name, op.GetType())
+	}
+
+	if fs.IsDir(copySource) {
+		copySource = filepath.Join(copySource, "values.yaml")
+	}
+	if fs.IsDir(copyTarget) {
+		copyTarget = filepath.Join(copyTarget, "values.yaml")
+	}
+
+	return editFile(copySource, copyTarget, true, func(source, target []byte) ([]byte, error) {
+		var err error
+		for _, key := range op.Keys {
+			if target, err = yamlpath.Copy(source, key, target, key); err != nil {
+				return nil, fmt.Errorf("copy operation %q: %w", op.Name, err)
+			}
+		}
+		return target, nil
+	})
+}
+
+// editFile writes the result of edit, which is given the contents of the
+// source and target files, to the target file if it differs.
+// A missing target file is passed as empty if create is true.
+func editFile(sourceFile string, targetFile string, create bool, edit func(source, target []byte) ([]byte, error)) error {
+	source, err := os.ReadFile(sourceFile)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	target, err := os.ReadFile(targetFile)
+	switch {
+	case os.IsNotExist(err) && create:
+	case err != nil:
+		return err
+	default:
+		fi, err := os.Stat(targetFile)
+		if err != nil {
+			return err
+		}
+		mode = fi.Mode()
+	}
+
+	out, err := edit(source, target)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(out, target) {
+		return nil
+	}
+
+	// Create target directory if it does not exist.
+	if err := os.MkdirAll(filepath.Dir(targetFile), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(targetFile, out, mode)
+}
+
+// CopyFiles copies the copySource file or directory to copyTarget.
+// If filter is not nil, only the files of a directory it returns true for are copied.
+func CopyFiles(copySource string, copyTarget string, filter func(path string) bool) error {
 	copySourceFileInfo, err := os.Stat(copySource)
 	if err != nil {
 		return err
 	}
 	copyTargetFileInfo, err := os.Stat(copyTarget)
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
 	// If source is a directory.
 	if copySourceFileInfo.IsDir() {
 		// Create target directory if it does not exist.
-		if err := os.MkdirAll(filepath.Dir(copyTarget), 0755); err != nil {
+		if err := os.MkdirAll(copyTarget, 0755); err != nil {
 			return err
 		}
 
-		if err := fs.CopyDirectory(copySource, copyTarget); err != nil {
+		if err := fs.CopyDirectoryFiltered(copySource, copyTarget, filter); err != nil {
 			return err
 		}
 		// If source is a file.
 	} else {
 		// Handle case when specified target is a directory.
-		if copyTargetFileInfo.IsDir() {
+		if copyTargetFileInfo != nil && copyTargetFileInfo.IsDir() {
 			copyTarget = filepath.Join(copyTarget, filepath.Base(copySource))
 		}
 
@@ -415,9 +691,106 @@ func CopyOperation(ctx context.Context, obj *promotionsv1alpha1.Promotion,
 	return nil
 }
 
+// requestsForEnvironment returns a reconcile request for each Promotion
+// which refers to the Environment as its source or target environment.
+func (r *PromotionReconciler) requestsForEnvironment(obj client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	seen := map[types.NamespacedName]bool{}
+	for _, indexKey := range []string{SourceEnvironmentRefIndexKey, TargetEnvironmentRefIndexKey} {
+		promotions := &promotionsv1alpha1.PromotionList{}
+		if err := r.List(context.Background(), promotions,
+			client.InNamespace(obj.GetNamespace()), client.MatchingFields{indexKey: obj.GetName()}); err != nil {
+			return nil
+		}
+		for _, promotion := range promotions.Items {
+			name := types.NamespacedName{Namespace: promotion.Namespace, Name: promotion.Name}
+			if !seen[name] {
+				seen[name] = true
+				requests = append(requests, reconcile.Request{NamespacedName: name})
+			}
+		}
+	}
+	return requests
+}
+
+// requestsForPromotionApproval returns a reconcile request for the Promotion
+// approved by the PromotionApproval.
+func (r *PromotionReconciler) requestsForPromotionApproval(obj client.Object) []reconcile.Request {
+	approval, ok := obj.(*promotionsv1alpha1.PromotionApproval)
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Namespace: approval.Namespace,
+		Name:      approval.Spec.PromotionRef.Name,
+	}}}
+}
+
+// environmentChanged returns true if the observed commit or the Ready
+// condition of the Environment changed, which may make a Promotion referring
+// to it promote.
+func environmentChanged(e event.UpdateEvent) bool {
+	oldEnvironment, ok := e.ObjectOld.(*promotionsv1alpha1.Environment)
+	if !ok {
+		return false
+	}
+	newEnvironment, ok := e.ObjectNew.(*promotionsv1alpha1.Environment)
+	if !ok {
+		return false
+	}
+	if oldEnvironment.Status.ObservedCommitHash != newEnvironment.Status.ObservedCommitHash {
+		return true
+	}
+	oldReady := meta.FindStatusCondition(oldEnvironment.Status.Conditions, promotionsv1alpha1.ReadyCondition)
+	newReady := meta.FindStatusCondition(newEnvironment.Status.Conditions, promotionsv1alpha1.ReadyCondition)
+	if oldReady == nil || newReady == nil {
+		return oldReady != newReady
+	}
+	return oldReady.Status != newReady.Status
+}
+
+// indexSourceEnvironmentRef and indexTargetEnvironmentRef index Promotions
+// by the names of their environments.
+func indexSourceEnvironmentRef(obj client.Object) []string {
+	promotion := obj.(*promotionsv1alpha1.Promotion)
+	if promotion.Spec.SourceEnvironmentRef == nil {
+		return nil
+	}
+	return []string{promotion.Spec.SourceEnvironmentRef.Name}
+}
+
+func indexTargetEnvironmentRef(obj client.Object) []string {
+	promotion := obj.(*promotionsv1alpha1.Promotion)
+	if promotion.Spec.TargetEnvironmentRef == nil {
+		return nil
+	}
+	return []string{promotion.Spec.TargetEnvironmentRef.Name}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *PromotionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	ctx := context.Background()
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &promotionsv1alpha1.Promotion{},
+		SourceEnvironmentRefIndexKey, indexSourceEnvironmentRef); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &promotionsv1alpha1.Promotion{},
+		TargetEnvironmentRefIndexKey, indexTargetEnvironmentRef); err != nil {
+		return err
+	}
+
+	// Status updates of the Promotion itself must not trigger a reconciliation,
+	// while requests by the ReconcileRequestAnnotation must.
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&promotionsv1alpha1.Promotion{}).
+		For(&promotionsv1alpha1.Promotion{}, builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}),
+		)).
+		Watches(&source.Kind{Type: &promotionsv1alpha1.Environment{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForEnvironment),
+			builder.WithPredicates(predicate.Funcs{UpdateFunc: environmentChanged})).
+		Watches(&source.Kind{Type: &promotionsv1alpha1.PromotionApproval{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForPromotionApproval)).
+		Watches(&source.Kind{Type: &promotionsv1alpha1.FreezeWindow{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForFreezeWindow)).
 		Complete(r)
 }
@@ -0,0 +1,264 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+func TestCopyOperation_YAMLPath(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "dev", "kustomization.yaml")
+	target := filepath.Join(dir, "prod", "kustomization.yaml")
+	g.Expect(os.MkdirAll(filepath.Dir(source), 0755)).To(Succeed())
+	g.Expect(os.WriteFile(source, []byte("images:\n- name: app\n  newTag: 1.1.0\n"), 0644)).To(Succeed())
+
+	op := promotionsv1alpha1.CopyOperation{
+		Name:     "Application Version",
+		Type:     promotionsv1alpha1.CopyOperationTypeYAMLPath,
+		YAMLPath: "images[0].newTag",
+	}
+
+	// The target file is created if it does not exist.
+	op.TargetYAMLPath = "app.tag"
+	g.Expect(CopyOperation(ctx, op, source, target)).To(Succeed())
+	g.Expect(os.ReadFile(target)).To(BeEquivalentTo("app:\n  tag: 1.1.0\n"))
+
+	// Replicas and comments of the target environment are kept.
+	g.Expect(os.WriteFile(target, []byte("replicas: 3 # prod\nimages:\n- name: app\n  newTag: 1.0.0\n"), 0644)).To(Succeed())
+	op.TargetYAMLPath = ""
+	g.Expect(CopyOperation(ctx, op, source, target)).To(Succeed())
+	g.Expect(os.ReadFile(target)).To(BeEquivalentTo("replicas: 3 # prod\nimages:\n- name: app\n  newTag: 1.1.0\n"))
+
+	op.YAMLPath = ""
+	g.Expect(CopyOperation(ctx, op, source, target)).To(MatchError(ContainSubstring("requires yamlPath")))
+}
+
+func TestCopyOperation_KustomizeImages(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "dev")
+	target := filepath.Join(dir, "prod")
+	g.Expect(os.MkdirAll(source, 0755)).To(Succeed())
+	g.Expect(os.MkdirAll(target, 0755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(source, "kustomization.yaml"),
+		[]byte("images:\n- name: app\n  newTag: 1.1.0\n- name: debug\n  newTag: latest\n"), 0644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(target, "kustomization.yaml"),
+		[]byte("images:\n- name: proxy\n  newTag: 2.0.0\n- name: app\n  newTag: 1.0.0\n"), 0644)).To(Succeed())
+
+	op := promotionsv1alpha1.CopyOperation{
+		Name:   "Application Version",
+		Type:   promotionsv1alpha1.CopyOperationTypeKustomizeImages,
+		Images: []string{"app"},
+	}
+	g.Expect(CopyOperation(ctx, op, source, target)).To(Succeed())
+	g.Expect(os.ReadFile(filepath.Join(target, "kustomization.yaml"))).
+		To(BeEquivalentTo("images:\n- name: proxy\n  newTag: 2.0.0\n- name: app\n  newTag: 1.1.0\n"))
+}
+
+func TestCopyOperation_HelmValues(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "dev")
+	target := filepath.Join(dir, "prod")
+	g.Expect(os.MkdirAll(source, 0755)).To(Succeed())
+	g.Expect(os.MkdirAll(target, 0755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(source, "values.yaml"), []byte(`image:
+  repository: ghcr.io/example/app
+  tag: 1.1.0
+replicaCount: 1
+app:
+  config:
+    featureFlags:
+      newCheckout: true # rolled out
+      darkMode: false
+`), 0644)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(target, "values.yaml"), []byte(`# Production values
+image:
+  repository: ghcr.io/example/app
+  tag: 1.0.0 # current release
+replicaCount: 5 # prod scale
+app:
+  config:
+    logLevel: warn
+`), 0644)).To(Succeed())
+
+	op := promotionsv1alpha1.CopyOperation{
+		Name: "Application",
+		Type: promotionsv1alpha1.CopyOperationTypeHelmValues,
+		Keys: []string{"image.tag", "app.config.featureFlags"},
+	}
+	g.Expect(CopyOperation(ctx, op, source, target)).To(Succeed())
+	g.Expect(os.ReadFile(filepath.Join(target, "values.yaml"))).To(BeEquivalentTo(`# Production values
+image:
+  repository: ghcr.io/example/app
+  tag: 1.1.0 # current release
+replicaCount: 5 # prod scale
+app:
+  config:
+    logLevel: warn
+    featureFlags:
+      newCheckout: true # rolled out
+      darkMode: false
+`))
+
+	op.Keys = []string{"image.digest"}
+	g.Expect(CopyOperation(ctx, op, source, target)).To(MatchError(ContainSubstring("path not found: image.digest")))
+}
+
+func TestEnvironmentChanged(t *testing.T) {
+	g := NewWithT(t)
+
+	environment := promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+	}
+	ready := promotionsv1alpha1.EnvironmentReady(environment, promotionsv1alpha1.SucceededReason, "cloned", "c1")
+	changed := func(oldEnvironment, newEnvironment promotionsv1alpha1.Environment) bool {
+		return environmentChanged(event.UpdateEvent{ObjectOld: &oldEnvironment, ObjectNew: &newEnvironment})
+	}
+
+	g.Expect(changed(environment, ready)).To(BeTrue())
+	g.Expect(changed(ready, promotionsv1alpha1.EnvironmentReady(*ready.DeepCopy(), promotionsv1alpha1.SucceededReason, "cloned", "c2"))).To(BeTrue())
+	g.Expect(changed(ready, promotionsv1alpha1.EnvironmentNotReady(*ready.DeepCopy(), "Failed", "clone failed"))).To(BeTrue())
+
+	// Only checking for new commits does not change the Environment.
+	checked := *ready.DeepCopy()
+	now := metav1.Now()
+	checked.Status.LastCheckedTime = &now
+	g.Expect(changed(ready, checked)).To(BeFalse())
+}
+
+func TestIndexEnvironmentRef(t *testing.T) {
+	g := NewWithT(t)
+
+	promotion := &promotionsv1alpha1.Promotion{
+		Spec: promotionsv1alpha1.PromotionSpec{
+			SourceEnvironmentRef: &corev1.LocalObjectReference{Name: "dev"},
+			TargetEnvironmentRef: &corev1.LocalObjectReference{Name: "prod"},
+		},
+	}
+	g.Expect(indexSourceEnvironmentRef(promotion)).To(ConsistOf("dev"))
+	g.Expect(indexTargetEnvironmentRef(promotion)).To(ConsistOf("prod"))
+	g.Expect(indexSourceEnvironmentRef(&promotionsv1alpha1.Promotion{})).To(BeEmpty())
+}
+
+func TestPromotionReconciler_Delete(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(promotionsv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	gitea := &fakeGitea{}
+	server := httptest.NewServer(gitea)
+	defer server.Close()
+
+	sourceURL := newTestRepository(t, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.1.0\n",
+	})
+	targetURL := newTestRepository(t, map[string]string{
+		"envs/prod/app-version/version.yaml": "version: 1.0.0\n",
+	})
+	source := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/dev", Source: promotionsv1alpha1.Source{URL: sourceURL}},
+	}
+	target := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.EnvironmentSpec{
+			Path:               "envs/prod",
+			Source:             promotionsv1alpha1.Source{URL: targetURL},
+			GitProvider:        promotionsv1alpha1.GitProviderGitea,
+			GitProviderBaseURL: server.URL,
+		},
+	}
+
+	// newDeletedPromotion opens a pull request for the promotion, and marks it as deleted.
+	newDeletedPromotion := func(deletionPolicy string) *promotionsv1alpha1.Promotion {
+		promotion := &promotionsv1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "dev-to-prod-" + deletionPolicy,
+				Namespace:  "default",
+				Finalizers: []string{promotionsv1alpha1.PromotionFinalizer},
+			},
+			Spec: promotionsv1alpha1.PromotionSpec{
+				SourceEnvironmentRef: &corev1.LocalObjectReference{Name: source.Name},
+				TargetEnvironmentRef: &corev1.LocalObjectReference{Name: target.Name},
+				Copy: []promotionsv1alpha1.CopyOperation{
+					{Name: "Application Version", Source: "app-version", Target: "app-version"},
+				},
+				Strategy:       promotionsv1alpha1.PromotionStrategyPullRequest,
+				DeletionPolicy: deletionPolicy,
+			},
+		}
+		g.Expect((&PullRequestStrategy{}).Promote(ctx, newTestPromotionRun(t, promotion, source, target))).To(Succeed())
+		now := metav1.Now()
+		promotion.DeletionTimestamp = &now
+		return promotion
+	}
+	reconcileDelete := func(c client.Client, promotion *promotionsv1alpha1.Promotion) {
+		r := &PromotionReconciler{Client: c, Scheme: scheme}
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(promotion)})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// The finalizer is removed, or the object is gone.
+		got := &promotionsv1alpha1.Promotion{}
+		g.Expect(client.IgnoreNotFound(c.Get(ctx, client.ObjectKeyFromObject(promotion), got))).To(Succeed())
+		g.Expect(got.Finalizers).To(BeEmpty())
+	}
+
+	// "orphan" leaves the pull request and its branch behind.
+	orphaned := newDeletedPromotion(promotionsv1alpha1.DeletionPolicyOrphan)
+	reconcileDelete(fake.NewClientBuilder().WithScheme(scheme).WithObjects(orphaned, source, target).Build(), orphaned)
+	g.Expect(gitea.state(1)).To(Equal("open"))
+	g.Expect(listTestRepositoryBranches(t, targetURL)).To(HaveLen(2))
+
+	// "close" closes the pull request with a comment, and deletes its branch.
+	closed := newDeletedPromotion(promotionsv1alpha1.DeletionPolicyClose)
+	reconcileDelete(fake.NewClientBuilder().WithScheme(scheme).WithObjects(closed, source, target).Build(), closed)
+	g.Expect(gitea.state(2)).To(Equal("closed"))
+	g.Expect(gitea.comments).To(ConsistOf(ContainSubstring("was deleted")))
+	g.Expect(listTestRepositoryBranches(t, targetURL)).To(HaveLen(2))
+
+	// The finalizer is removed if the target environment is gone.
+	gone := newDeletedPromotion(promotionsv1alpha1.DeletionPolicyClose)
+	gone.Name = "dev-to-prod-gone"
+	reconcileDelete(fake.NewClientBuilder().WithScheme(scheme).WithObjects(gone, source).Build(), gone)
+	g.Expect(gitea.state(3)).To(Equal("open"))
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/schedule"
+)
+
+// NewScheduleWindow parses the window, named name in messages.
+// Its time zone defaults to timeZone.
+func NewScheduleWindow(name string, w promotionsv1alpha1.ScheduleWindow, timeZone string) (schedule.Window, error) {
+	if w.TimeZone != "" {
+		timeZone = w.TimeZone
+	}
+	return schedule.NewWindow(name, w.Cron, w.Duration.Duration, timeZone)
+}
+
+// PromotionSchedule returns the schedule of the Promotion, which is denied
+// to promote during the windows of the FreezeWindows selecting it.
+func PromotionSchedule(ctx context.Context, c client.Client, obj *promotionsv1alpha1.Promotion) (*schedule.Schedule, error) {
+	s := &schedule.Schedule{}
+	if spec := obj.Spec.Schedule; spec != nil {
+		for _, w := range spec.Allow {
+			window, err := NewScheduleWindow("", w, spec.TimeZone)
+			if err != nil {
+				return nil, err
+			}
+			s.Allow = append(s.Allow, window)
+		}
+		for _, w := range spec.Deny {
+			window, err := NewScheduleWindow(fmt.Sprintf("by deny window %q", w.Cron), w, spec.TimeZone)
+			if err != nil {
+				return nil, err
+			}
+			s.Deny = append(s.Deny, window)
+		}
+	}
+
+	freezeWindows := &promotionsv1alpha1.FreezeWindowList{}
+	if err := c.List(ctx, freezeWindows); err != nil {
+		return nil, err
+	}
+	for _, freeze := range freezeWindows.Items {
+		selected, err := freeze.Selects(obj)
+		if err != nil {
+			return nil, fmt.Errorf("FreezeWindow %s: %w", freeze.Name, err)
+		}
+		if !selected {
+			continue
+		}
+		name := fmt.Sprintf("by FreezeWindow %s", freeze.Name)
+		if freeze.Spec.Reason != "" {
+			name += fmt.Sprintf(" (%s)", freeze.Spec.Reason)
+		}
+		for _, w := range freeze.Spec.Windows {
+			window, err := NewScheduleWindow(name, w, freeze.Spec.TimeZone)
+			if err != nil {
+				return nil, fmt.Errorf("FreezeWindow %s: %w", freeze.Name, err)
+			}
+			s.Deny = append(s.Deny, window)
+		}
+	}
+	return s, nil
+}
+
+// GetScheduleBlock returns why, and until when, the schedule of the Promotion,
+// or a FreezeWindow selecting it, blocks it from promoting changes at now.
+// It returns nil if changes may be promoted.
+func GetScheduleBlock(ctx context.Context, c client.Client, obj *promotionsv1alpha1.Promotion, now time.Time) (*schedule.Block, error) {
+	s, err := PromotionSchedule(ctx, c, obj)
+	if err != nil {
+		return nil, err
+	}
+	return s.Blocked(now), nil
+}
+
+// SetScheduleBlock records on the Promotion whether, and until when, it is
+// blocked by its schedule.
+func SetScheduleBlock(obj *promotionsv1alpha1.Promotion, block *schedule.Block) {
+	if block == nil {
+		*obj = promotionsv1alpha1.PromotionUnblocked(*obj)
+		return
+	}
+	var until *metav1.Time
+	if !block.Until.IsZero() {
+		t := metav1.NewTime(block.Until)
+		until = &t
+	}
+	*obj = promotionsv1alpha1.PromotionBlocked(*obj, until, ScheduleBlockMessage(block))
+}
+
+// ScheduleBlockMessage describes why, and until when, changes are not promoted.
+func ScheduleBlockMessage(block *schedule.Block) string {
+	if block.Until.IsZero() {
+		return fmt.Sprintf("Changes are blocked %s, and no allowed time was found", block.Reason)
+	}
+	return fmt.Sprintf("Changes are blocked %s until %s", block.Reason, block.Until.UTC().Format(time.RFC3339))
+}
+
+// requestsForFreezeWindow returns a reconcile request for each Promotion
+// selected by the FreezeWindow.
+func (r *PromotionReconciler) requestsForFreezeWindow(obj client.Object) []reconcile.Request {
+	freeze, ok := obj.(*promotionsv1alpha1.FreezeWindow)
+	if !ok {
+		return nil
+	}
+	promotions := &promotionsv1alpha1.PromotionList{}
+	if err := r.List(context.Background(), promotions); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range promotions.Items {
+		promotion := &promotions.Items[i]
+		if selected, err := freeze.Selects(promotion); err != nil || !selected {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: promotion.Namespace, Name: promotion.Name},
+		})
+	}
+	return requests
+}
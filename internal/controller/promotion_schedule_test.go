@@ -0,0 +1,117 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+func TestGetScheduleBlock(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(promotionsv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	// The year-end freeze of the production promotions.
+	freeze := &promotionsv1alpha1.FreezeWindow{
+		ObjectMeta: metav1.ObjectMeta{Name: "year-end"},
+		Spec: promotionsv1alpha1.FreezeWindowSpec{
+			Windows: []promotionsv1alpha1.ScheduleWindow{
+				{Cron: "0 0 20 12 *", Duration: metav1.Duration{Duration: 13 * 24 * time.Hour}},
+			},
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "prod"}},
+			Reason:   "Year-end change freeze",
+		},
+	}
+	// A maintenance of the cluster, which only affects the "shop" namespace.
+	maintenance := &promotionsv1alpha1.FreezeWindow{
+		ObjectMeta: metav1.ObjectMeta{Name: "maintenance"},
+		Spec: promotionsv1alpha1.FreezeWindowSpec{
+			Windows: []promotionsv1alpha1.ScheduleWindow{
+				{Cron: "0 22 15 3 *", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+			},
+			Namespaces: []string{"shop"},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(freeze, maintenance).Build()
+
+	newPromotion := func(namespace string, environment string) *promotionsv1alpha1.Promotion {
+		return &promotionsv1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "promotion",
+				Namespace: namespace,
+				Labels:    map[string]string{"environment": environment},
+			},
+			Spec: promotionsv1alpha1.PromotionSpec{
+				Schedule: &promotionsv1alpha1.PromotionSchedule{
+					Deny: []promotionsv1alpha1.ScheduleWindow{
+						// No promotions on Fridays after 14:00.
+						{Cron: "0 14 * * FRI", Duration: metav1.Duration{Duration: 10 * time.Hour}},
+					},
+				},
+			},
+		}
+	}
+	prod := newPromotion("default", "prod")
+	staging := newPromotion("default", "staging")
+	shop := newPromotion("shop", "staging")
+
+	// Neither the schedule nor a FreezeWindow blocks on a Wednesday.
+	wednesday := time.Date(2023, time.December, 13, 10, 0, 0, 0, time.UTC)
+	for _, promotion := range []*promotionsv1alpha1.Promotion{prod, staging, shop} {
+		block, err := GetScheduleBlock(ctx, c, promotion, wednesday)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(block).To(BeNil())
+	}
+
+	// The deny window of the schedule.
+	block, err := GetScheduleBlock(ctx, c, staging, time.Date(2023, time.December, 15, 15, 0, 0, 0, time.UTC))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(block).ToNot(BeNil())
+	g.Expect(ScheduleBlockMessage(block)).To(Equal(`Changes are blocked by deny window "0 14 * * FRI" until 2023-12-16T00:00:00Z`))
+
+	// The year-end freeze only selects the production promotion.
+	christmas := time.Date(2023, time.December, 27, 10, 0, 0, 0, time.UTC)
+	block, err = GetScheduleBlock(ctx, c, prod, christmas)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(block).ToNot(BeNil())
+	g.Expect(ScheduleBlockMessage(block)).To(Equal("Changes are blocked by FreezeWindow year-end (Year-end change freeze) until 2024-01-02T00:00:00Z"))
+	block, err = GetScheduleBlock(ctx, c, staging, christmas)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(block).To(BeNil())
+
+	// The maintenance only selects the promotions in its namespaces.
+	maintenanceTime := time.Date(2023, time.March, 15, 23, 0, 0, 0, time.UTC)
+	block, err = GetScheduleBlock(ctx, c, shop, maintenanceTime)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(block).ToNot(BeNil())
+	g.Expect(block.Reason).To(Equal("by FreezeWindow maintenance"))
+	g.Expect(block.Until).To(BeTemporally("==", time.Date(2023, time.March, 16, 2, 0, 0, 0, time.UTC)))
+	block, err = GetScheduleBlock(ctx, c, staging, maintenanceTime)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(block).To(BeNil())
+}
@@ -0,0 +1,436 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/schedule"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/signing"
+)
+
+// PromotionStrategy promotes the copy operations of a Promotion from the
+// source environment to the target environment.
+// Implementations are expected to update the status of the Promotion.
+type PromotionStrategy interface {
+	Promote(ctx context.Context, run *PromotionRun) error
+}
+
+// NewPromotionStrategy returns the PromotionStrategy for the given strategy name.
+func NewPromotionStrategy(client client.Client, strategy string) (PromotionStrategy, error) {
+	switch strategy {
+	case promotionsv1alpha1.PromotionStrategyPullRequest:
+		return &PullRequestStrategy{Client: client}, nil
+	case promotionsv1alpha1.PromotionStrategyPush:
+		return &PushStrategy{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported promotion strategy %q", strategy)
+	}
+}
+
+// PromotionRun holds the state of a single reconciliation of a Promotion,
+// with both environment repositories cloned to disk.
+type PromotionRun struct {
+	Promotion         *promotionsv1alpha1.Promotion
+	SourceEnvironment *promotionsv1alpha1.Environment
+	TargetEnvironment *promotionsv1alpha1.Environment
+
+	SourceEnvironmentRepo     *gogit.Repository
+	TargetEnvironmentRepo     *gogit.Repository
+	TargetEnvironmentWorktree *gogit.Worktree
+
+	// SourceEnvironmentPath and TargetEnvironmentPath are the root
+	// directories of the cloned repositories.
+	SourceEnvironmentPath string
+	TargetEnvironmentPath string
+
+	SourceEnvironmentLatestCommit *object.Commit
+
+	// TargetGitAuth and TargetCloneURL are used to fetch from and push to
+	// the target environment repository.
+	TargetGitAuth  transport.AuthMethod
+	TargetCloneURL string
+
+	// SigningKey is the key the commits are signed with, or nil if they are not signed.
+	SigningKey *signing.Key
+
+	// Approval is the PromotionApproval of the latest commit of the source
+	// environment, or nil if there is none, or the Promotion requires none.
+	Approval *promotionsv1alpha1.PromotionApproval
+	// IgnoreApprovals is set if PromotionApprovals can't be trusted, as the
+	// admission webhooks which record their approvers are disabled.
+	IgnoreApprovals bool
+
+	// Block is set if the schedule of the Promotion, or a FreezeWindow,
+	// blocks it from promoting changes at the time of the run.
+	Block *schedule.Block
+}
+
+// AwaitSchedule returns true if the Promotion is blocked by its schedule,
+// or a FreezeWindow, in which case nothing must be pushed.
+func (run *PromotionRun) AwaitSchedule() bool {
+	if run.Block == nil {
+		return false
+	}
+	*run.Promotion = promotionsv1alpha1.PromotionNotReady(*run.Promotion, promotionsv1alpha1.BlockedByScheduleReason, ScheduleBlockMessage(run.Block))
+	return true
+}
+
+// AwaitApproval returns true if the Promotion requires an approval, and the
+// latest commit of the source environment has not been approved, in which case
+// nothing must be pushed. It updates the ApprovedCondition of the Promotion.
+func (run *PromotionRun) AwaitApproval() bool {
+	obj := run.Promotion
+	if obj.Spec.Approval == nil {
+		apimeta.RemoveStatusCondition(&obj.Status.Conditions, promotionsv1alpha1.ApprovedCondition)
+		return false
+	}
+
+	if run.IgnoreApprovals {
+		*obj = promotionsv1alpha1.PromotionWaitingForApproval(*obj,
+			"PromotionApprovals are ignored, as the admission webhooks which record their approvers are disabled")
+		return true
+	}
+
+	sourceCommit := run.SourceEnvironmentLatestCommit.Hash.String()
+	if run.Approval == nil || run.Approval.Spec.SourceCommitHash != sourceCommit {
+		*obj = promotionsv1alpha1.PromotionWaitingForApproval(*obj,
+			fmt.Sprintf("Waiting for a PromotionApproval of commit %s of source environment %s", sourceCommit, run.SourceEnvironment.Name))
+		return true
+	}
+	*obj = promotionsv1alpha1.PromotionApproved(*obj, sourceCommit, run.Approval.Spec.ApprovedBy)
+	return false
+}
+
+// CommitCopyOperations performs the copy operations of the Promotion on the
+// currently checked out branch of the target environment, and creates one
+// commit for every copy operation which introduced changes.
+// It returns the names of the copy operations which were committed.
+func (run *PromotionRun) CommitCopyOperations(ctx context.Context) ([]string, error) {
+	var promotedSubjects []string
+
+	data, err := run.TemplateData()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, copyOperation := range run.Promotion.Spec.Copy {
+		copySource, copyTarget, err := run.copyPaths(copyOperation)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := CopyOperation(ctx, copyOperation, copySource, copyTarget); err != nil {
+			return nil, err
+		}
+
+		staged, err := run.stageCopyOperation(copyOperation, copySource, copyTarget)
+		if err != nil {
+			return nil, err
+		}
+		if !staged {
+			continue
+		}
+
+		data.CopyOperation = copyOperation
+		commitMsg, err := CommitMessage(data)
+		if err != nil {
+			return nil, err
+		}
+		if run.Promotion.Spec.CoAuthoredBy {
+			commitMsg = AddCoAuthors(commitMsg, data.Commits)
+		}
+
+		signature := run.CommitSignature()
+		_, err = signing.Commit(run.TargetEnvironmentRepo, commitMsg,
+			&gogit.CommitOptions{
+				Author:    signature,
+				Committer: signature,
+			}, run.SigningKey)
+		if err != nil {
+			return nil, err
+		}
+
+		promotedSubjects = append(promotedSubjects, copyOperation.Name)
+	}
+
+	return promotedSubjects, nil
+}
+
+// CommitSignature returns the author and committer of the commits of the run.
+// The commit author of the Promotion takes precedence over the one of the
+// target environment.
+func (run *PromotionRun) CommitSignature() *object.Signature {
+	author := promotionsv1alpha1.CommitAuthor{
+		Name:  promotionsv1alpha1.DefaultCommitAuthorName,
+		Email: promotionsv1alpha1.DefaultCommitAuthorEmail,
+	}
+	if run.TargetEnvironment.Spec.CommitAuthor != nil {
+		author = *run.TargetEnvironment.Spec.CommitAuthor
+	}
+	if run.Promotion.Spec.CommitAuthor != nil {
+		author = *run.Promotion.Spec.CommitAuthor
+	}
+	return &object.Signature{
+		Name:  author.Name,
+		Email: author.Email,
+		When:  time.Now(),
+	}
+}
+
+// AddCoAuthors appends a "Co-authored-by" trailer for each distinct author of
+// the commits to the commit message.
+func AddCoAuthors(msg string, commits []ChangelogCommit) string {
+	var trailers []string
+	seen := map[string]bool{}
+	for _, c := range commits {
+		trailer := fmt.Sprintf("Co-authored-by: %s <%s>", c.Author, c.AuthorEmail)
+		if seen[trailer] {
+			continue
+		}
+		seen[trailer] = true
+		trailers = append(trailers, trailer)
+	}
+	if len(trailers) == 0 {
+		return msg
+	}
+	return strings.TrimRight(msg, "\n") + "\n\n" + strings.Join(trailers, "\n") + "\n"
+}
+
+// copyPaths returns the source and target paths of the copy operation
+// in the cloned repositories.
+func (run *PromotionRun) copyPaths(op promotionsv1alpha1.CopyOperation) (string, string, error) {
+	sourceEnvironmentFullPath := filepath.Join(run.SourceEnvironmentPath, run.SourceEnvironment.Spec.Path)
+	targetEnvironmentFullPath := filepath.Join(run.TargetEnvironmentPath, run.TargetEnvironment.Spec.Path)
+
+	copySource, err := securejoin.SecureJoin(sourceEnvironmentFullPath, op.Source)
+	if err != nil {
+		return "", "", err
+	}
+	copyTarget, err := securejoin.SecureJoin(targetEnvironmentFullPath, op.Target)
+	if err != nil {
+		return "", "", err
+	}
+	return copySource, copyTarget, nil
+}
+
+// copyOperationMatcher returns a function which reports whether a path of the
+// target environment repository, relative to its root, is written by the copy
+// operation, i.e. it is inside of the copy target, and selected by the include
+// and exclude patterns of the copy operation.
+func (run *PromotionRun) copyOperationMatcher(op promotionsv1alpha1.CopyOperation, copySource string, copyTarget string) (func(string) bool, error) {
+	filter, err := CopyFilter(op)
+	if err != nil {
+		return nil, err
+	}
+	target, err := filepath.Rel(run.TargetEnvironmentPath, CopyTargetPath(op, copySource, copyTarget))
+	if err != nil {
+		return nil, err
+	}
+	target = filepath.ToSlash(target)
+
+	return func(path string) bool {
+		if path == target {
+			return true
+		}
+		rel := path
+		if target != "." {
+			rel = strings.TrimPrefix(path, target+"/")
+			if rel == path {
+				return false
+			}
+		}
+		return filter == nil || filter(rel)
+	}, nil
+}
+
+// stageCopyOperation adds the changes made by the copy operation to the
+// target environment git worktree, including deleted files. Changes outside
+// of the copy target, or not selected by the include and exclude patterns of
+// the copy operation, are not staged. It returns whether changes were staged.
+func (run *PromotionRun) stageCopyOperation(op promotionsv1alpha1.CopyOperation, copySource string, copyTarget string) (bool, error) {
+	matches, err := run.copyOperationMatcher(op, copySource, copyTarget)
+	if err != nil {
+		return false, err
+	}
+
+	status, err := run.TargetEnvironmentWorktree.Status()
+	if err != nil {
+		return false, err
+	}
+	var paths []string
+	for path, fileStatus := range status {
+		if fileStatus.Worktree != gogit.Unmodified && matches(path) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if _, err := run.TargetEnvironmentWorktree.Add(path); err != nil {
+			return false, err
+		}
+	}
+	return len(paths) > 0, nil
+}
+
+// CopyOperationChanges lists the files changed by a copy operation.
+type CopyOperationChanges struct {
+	// Name is the name of the copy operation.
+	Name string
+	// Files are the changed paths, relative to the root of the target environment repository.
+	Files []string
+}
+
+// ChangedFiles returns the files changed on the checked out branch of the target
+// environment since it diverged from the branch of the target environment,
+// grouped by the copy operations of the Promotion which changed them.
+// Copy operations without changes are omitted.
+func (run *PromotionRun) ChangedFiles() ([]CopyOperationChanges, error) {
+	head, err := run.TargetEnvironmentRepo.Head()
+	if err != nil {
+		return nil, err
+	}
+	headCommit, err := run.TargetEnvironmentRepo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	base, err := run.TargetEnvironmentRepo.Reference(plumbing.NewBranchReferenceName(run.TargetEnvironment.GetBranch()), true)
+	if err != nil {
+		return nil, err
+	}
+	baseCommit, err := run.TargetEnvironmentRepo.CommitObject(base.Hash())
+	if err != nil {
+		return nil, err
+	}
+	mergeBases, err := baseCommit.MergeBase(headCommit)
+	if err != nil {
+		return nil, err
+	}
+	if len(mergeBases) > 0 {
+		baseCommit = mergeBases[0]
+	}
+
+	baseTree, err := baseCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTree(baseTree, headTree)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, change := range changes {
+		if change.To.Name != "" {
+			paths = append(paths, change.To.Name)
+		} else {
+			paths = append(paths, change.From.Name)
+		}
+	}
+	sort.Strings(paths)
+
+	var result []CopyOperationChanges
+	for _, op := range run.Promotion.Spec.Copy {
+		copySource, copyTarget, err := run.copyPaths(op)
+		if err != nil {
+			return nil, err
+		}
+		matches, err := run.copyOperationMatcher(op, copySource, copyTarget)
+		if err != nil {
+			return nil, err
+		}
+		opChanges := CopyOperationChanges{Name: op.Name}
+		for _, path := range paths {
+			if matches(path) {
+				opChanges.Files = append(opChanges.Files, path)
+			}
+		}
+		if len(opChanges.Files) > 0 {
+			result = append(result, opChanges)
+		}
+	}
+	return result, nil
+}
+
+// Push pushes the given branch to the target environment repository.
+func (run *PromotionRun) Push(ctx context.Context, branch string) error {
+	refSpec := config.RefSpec(fmt.Sprintf("%s:%s", plumbing.NewBranchReferenceName(branch), plumbing.NewBranchReferenceName(branch)))
+	return run.TargetEnvironmentRepo.PushContext(ctx, &gogit.PushOptions{
+		RemoteName: "origin",
+		RemoteURL:  run.TargetCloneURL,
+		RefSpecs:   []config.RefSpec{refSpec},
+		Auth:       run.TargetGitAuth,
+	})
+}
+
+// DeleteBranch deletes the given branch from the target environment repository.
+// It does nothing if the branch does not exist.
+func (run *PromotionRun) DeleteBranch(ctx context.Context, branch string) error {
+	return DeleteRemoteBranch(ctx, run.TargetCloneURL, run.TargetGitAuth, branch)
+}
+
+// DeleteRemoteBranch deletes the given branch from the repository at url,
+// without cloning it. It does nothing if the branch does not exist.
+func DeleteRemoteBranch(ctx context.Context, url string, auth transport.AuthMethod, branch string) error {
+	remote := gogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{url},
+	})
+	refSpec := config.RefSpec(fmt.Sprintf(":%s", plumbing.NewBranchReferenceName(branch)))
+	err := remote.PushContext(ctx, &gogit.PushOptions{
+		RefSpecs: []config.RefSpec{refSpec},
+		Auth:     auth,
+	})
+	if errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+// MarkSynced records on the Promotion that the target environment, at the
+// commit currently checked out, contains all changes of the source environment.
+func (run *PromotionRun) MarkSynced() error {
+	head, err := run.TargetEnvironmentRepo.Head()
+	if err != nil {
+		return err
+	}
+	*run.Promotion = promotionsv1alpha1.PromotionSynced(*run.Promotion,
+		run.SourceEnvironmentLatestCommit.Hash.String(), head.Hash().String())
+	return nil
+}
@@ -0,0 +1,287 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/provider"
+)
+
+// PullRequestStrategy commits the copy operations to a promotion branch,
+// and opens (or updates) a pull request to the branch of the target environment.
+type PullRequestStrategy struct {
+	Client client.Client
+}
+
+func (s *PullRequestStrategy) Promote(ctx context.Context, run *PromotionRun) error {
+	log := log.FromContext(ctx)
+	obj := run.Promotion
+
+	// Get the pull request provider for the target environment
+	targetEnvironmentProvider, err := NewPullRequestProvider(ctx, s.Client, run.TargetEnvironment, run.TargetEnvironmentRepo)
+	if err != nil {
+		return err
+	}
+
+	prs, err := targetEnvironmentProvider.ListPullRequests(ctx)
+	if err != nil {
+		return err
+	}
+
+	// isPROpen tells us whether there's already an open pull request for this promotion
+	var isPROpen bool
+	if obj.Status.LastPullRequestNumber != 0 {
+		for _, pr := range prs {
+			if pr.Number == obj.Status.LastPullRequestNumber {
+				isPROpen = true
+				break
+			}
+		}
+	}
+
+	var pr provider.PullRequest
+	if obj.Status.LastPullRequestNumber != 0 && (isPROpen || obj.Status.LastPullRequestState == promotionsv1alpha1.PullRequestStateOpen || obj.Status.LastPullRequestState == "") {
+		pr, err = targetEnvironmentProvider.GetPullRequest(ctx, obj.Status.LastPullRequestNumber)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The last pull request has been merged or closed since the last reconciliation.
+	if !isPROpen && pr.Number != 0 {
+		if err := s.pullRequestFinished(ctx, run, pr); err != nil {
+			return err
+		}
+	}
+
+	// Close the open pull request if it is outdated, and a new one should be opened instead.
+	if isPROpen && obj.Spec.OnSourceChange == promotionsv1alpha1.OnSourceChangeRecreate && pullRequestOutdated(run) {
+		// Keep the open pull request while the schedule blocks the promotion,
+		// and until its replacement is approved.
+		if run.AwaitSchedule() {
+			log.Info("Blocked by schedule, not superseding pull request", "WebURL", pr.WebURL, "until", run.Block.Until)
+			return nil
+		}
+		if run.AwaitApproval() {
+			log.Info("Waiting for approval, not superseding pull request", "WebURL", pr.WebURL, "sourceCommit", run.SourceEnvironmentLatestCommit.Hash.String())
+			return nil
+		}
+
+		comment := fmt.Sprintf("Superseded by a new pull request, as the source environment %s moved on to %s, or the promotion %s was changed.",
+			run.SourceEnvironment.Name, run.SourceEnvironmentLatestCommit.Hash.String()[0:7], obj.Name)
+		if err := targetEnvironmentProvider.ClosePullRequest(ctx, pr.Number, comment); err != nil {
+			return err
+		}
+		if err := run.DeleteBranch(ctx, pr.SourceBranch); err != nil {
+			return err
+		}
+		log.Info("Closed outdated pull request", "WebURL", pr.WebURL)
+		obj.Status.LastPullRequestState = promotionsv1alpha1.PullRequestStateClosed
+		isPROpen = false
+	}
+
+	// Don't open a new pull request right away, if the last one was closed without being merged.
+	if !isPROpen && apimeta.IsStatusConditionTrue(obj.Status.Conditions, promotionsv1alpha1.PullRequestClosedCondition) && !mayReopenPullRequest(run) {
+		*obj = promotionsv1alpha1.PromotionReady(*obj, promotionsv1alpha1.SucceededReason,
+			fmt.Sprintf("Pull request #%d was closed without being merged, waiting for new changes before opening a new one.", obj.Status.LastPullRequestNumber))
+		return nil
+	}
+
+	var branch string
+	if isPROpen {
+		branch = pr.SourceBranch
+
+		if err := run.TargetEnvironmentRepo.Fetch(&gogit.FetchOptions{
+			RefSpecs:  []config.RefSpec{"refs/*:refs/*", "HEAD:refs/heads/HEAD"},
+			Auth:      run.TargetGitAuth,
+			RemoteURL: run.TargetCloneURL,
+		}); err != nil {
+			return err
+		}
+
+		if err = run.TargetEnvironmentWorktree.Checkout(&gogit.CheckoutOptions{
+			Branch: plumbing.ReferenceName(fmt.Sprintf("refs/heads/%s", branch)),
+			Force:  true,
+		}); err != nil {
+			return err
+		}
+	} else {
+		branch = fmt.Sprintf("promotion/%s-%s", obj.Name, time.Now().Format("2006-01-02-15-04-05"))
+
+		if err := run.TargetEnvironmentWorktree.Checkout(&gogit.CheckoutOptions{
+			Branch: plumbing.NewBranchReferenceName(branch),
+			Create: true,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Copy the promotion subjects from the source environment to the target environment
+	promotedSubjects, err := run.CommitCopyOperations(ctx)
+	if err != nil {
+		return err
+	}
+
+	// If we introduced new commits
+	if len(promotedSubjects) > 0 {
+		// Neither open nor update the pull request while the schedule blocks
+		// the promotion, or until the changes are approved.
+		if run.AwaitSchedule() {
+			log.Info("Blocked by schedule, not pushing to pull request", "until", run.Block.Until)
+			return nil
+		}
+		if run.AwaitApproval() {
+			log.Info("Waiting for approval, not pushing to pull request", "sourceCommit", run.SourceEnvironmentLatestCommit.Hash.String())
+			return nil
+		}
+
+		if err := run.Push(ctx, branch); err != nil {
+			return err
+		}
+		*obj = promotionsv1alpha1.PromotionReady(*obj, promotionsv1alpha1.SucceededReason, "Pushed new commits to PR branch")
+
+		prTitle, prBody, err := pullRequestTitleAndBody(run, promotedSubjects)
+		if err != nil {
+			return err
+		}
+
+		if isPROpen {
+			_, err = targetEnvironmentProvider.EditPullRequest(ctx, pr.Number, prTitle, prBody)
+			if err != nil {
+				return err
+			}
+		} else {
+			pr, err = targetEnvironmentProvider.CreatePullRequest(ctx, prTitle, branch, run.TargetEnvironment.GetBranch(), prBody)
+			if err != nil {
+				return err
+			}
+			isPROpen = true
+
+			log.Info("Created new pull request", "WebURL", pr.WebURL)
+			*obj = promotionsv1alpha1.PromotionReady(*obj, promotionsv1alpha1.SucceededReason, "New Pull request created successfully")
+
+			obj.Status.LastPullRequestNumber = pr.Number
+			obj.Status.LastPullRequestURL = pr.WebURL
+			obj.Status.LastPullRequestState = promotionsv1alpha1.PullRequestStateOpen
+			apimeta.RemoveStatusCondition(&obj.Status.Conditions, promotionsv1alpha1.PullRequestClosedCondition)
+		}
+	} else {
+		*obj = promotionsv1alpha1.PromotionReady(*obj, promotionsv1alpha1.SucceededReason, "A pull request is open for review.")
+	}
+
+	// If there's no open PR at this point, we assume that the source and target environments are in sync.
+	if !isPROpen {
+		*obj = promotionsv1alpha1.PromotionReady(*obj, promotionsv1alpha1.SucceededReason, "Source and target environments are in sync, nothing to promote.")
+		apimeta.RemoveStatusCondition(&obj.Status.Conditions, promotionsv1alpha1.PullRequestClosedCondition)
+		// Nothing was committed, so the checked out branch is still at the head of the target environment.
+		return run.MarkSynced()
+	}
+
+	// The open pull request now contains the changes of the source environment at its latest commit.
+	obj.Status.LastPullRequestSourceCommitHash = run.SourceEnvironmentLatestCommit.Hash.String()
+	obj.Status.LastPullRequestGeneration = obj.Generation
+
+	return nil
+}
+
+// pullRequestFinished records that the last pull request of the promotion has
+// been merged or closed, and deletes its branch.
+func (s *PullRequestStrategy) pullRequestFinished(ctx context.Context, run *PromotionRun, pr provider.PullRequest) error {
+	obj := run.Promotion
+
+	if err := run.DeleteBranch(ctx, pr.SourceBranch); err != nil {
+		return err
+	}
+
+	if pr.Merged {
+		log.FromContext(ctx).Info("Pull request has been merged", "WebURL", pr.WebURL)
+		obj.Status.LastPullRequestState = promotionsv1alpha1.PullRequestStateMerged
+		if obj.Status.LastPullRequestSourceCommitHash != "" {
+			obj.Status.LastPromotedSourceCommitHash = obj.Status.LastPullRequestSourceCommitHash
+		}
+		apimeta.RemoveStatusCondition(&obj.Status.Conditions, promotionsv1alpha1.PullRequestClosedCondition)
+		return nil
+	}
+
+	log.FromContext(ctx).Info("Pull request has been closed without being merged", "WebURL", pr.WebURL)
+	obj.Status.LastPullRequestState = promotionsv1alpha1.PullRequestStateClosed
+	*obj = promotionsv1alpha1.PromotionPullRequestClosed(*obj,
+		fmt.Sprintf("Pull request #%d was closed without being merged.", pr.Number))
+	return nil
+}
+
+// pullRequestTitleAndBody renders the title and description of the pull request,
+// with the changelog of the source environment since the last promotion, and
+// the files changed by the pull request.
+func pullRequestTitleAndBody(run *PromotionRun, promotedSubjects []string) (string, string, error) {
+	data, err := run.TemplateData()
+	if err != nil {
+		return "", "", err
+	}
+	data.PromotedSubjects = promotedSubjects
+	data.CopyOperations, err = run.ChangedFiles()
+	if err != nil {
+		return "", "", err
+	}
+
+	title, err := PullRequestTitle(data)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := PullRequestBody(data)
+	if err != nil {
+		return "", "", err
+	}
+	return title, body, nil
+}
+
+// pullRequestOutdated returns true if the source environment moved on, or the
+// promotion was changed, since the changes were last pushed to the pull request.
+func pullRequestOutdated(run *PromotionRun) bool {
+	obj := run.Promotion
+	if obj.Status.LastPullRequestSourceCommitHash == "" {
+		return false
+	}
+	return obj.Status.LastPullRequestSourceCommitHash != run.SourceEnvironmentLatestCommit.Hash.String() ||
+		obj.Status.LastPullRequestGeneration != obj.Generation
+}
+
+// mayReopenPullRequest returns true if a new pull request may be opened, after
+// the last one was closed without being merged.
+func mayReopenPullRequest(run *PromotionRun) bool {
+	obj := run.Promotion
+	if pullRequestOutdated(run) {
+		return true
+	}
+	if obj.Spec.ReopenAfter == nil {
+		return false
+	}
+	closed := apimeta.FindStatusCondition(obj.Status.Conditions, promotionsv1alpha1.PullRequestClosedCondition)
+	return closed != nil && time.Since(closed.LastTransitionTime.Time) >= obj.Spec.ReopenAfter.Duration
+}
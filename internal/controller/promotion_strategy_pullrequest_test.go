@@ -0,0 +1,394 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/storage/memory"
+	. "github.com/onsi/gomega"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/schedule"
+)
+
+// fakeGitea is a minimal stand-in for the pull request API of a Gitea server,
+// serving any repository.
+type fakeGitea struct {
+	mu       sync.Mutex
+	prs      []map[string]interface{}
+	comments []string
+}
+
+var fakeGiteaPath = regexp.MustCompile(`^/api/v1/repos/[^/]+/[^/]+/(pulls|issues)(?:/(\d+))?(/comments)?$`)
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m := fakeGiteaPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var in map[string]string
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&in)
+	}
+
+	switch {
+	case m[1] == "issues" && m[3] != "" && r.Method == http.MethodPost:
+		f.comments = append(f.comments, in["body"])
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(in)
+	case m[1] == "pulls" && m[2] == "" && r.Method == http.MethodGet:
+		open := []map[string]interface{}{}
+		for _, pr := range f.prs {
+			if pr["state"] == "open" {
+				open = append(open, pr)
+			}
+		}
+		json.NewEncoder(w).Encode(open)
+	case m[1] == "pulls" && m[2] == "" && r.Method == http.MethodPost:
+		n := len(f.prs) + 1
+		pr := map[string]interface{}{
+			"number":   n,
+			"title":    in["title"],
+			"body":     in["body"],
+			"html_url": fmt.Sprintf("http://%s/pulls/%d", r.Host, n),
+			"state":    "open",
+			"merged":   false,
+			"head":     map[string]string{"ref": in["head"]},
+		}
+		f.prs = append(f.prs, pr)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(pr)
+	case m[1] == "pulls" && m[2] != "":
+		n, _ := strconv.Atoi(m[2])
+		if n < 1 || n > len(f.prs) {
+			http.NotFound(w, r)
+			return
+		}
+		pr := f.prs[n-1]
+		if r.Method == http.MethodPatch {
+			for _, key := range []string{"title", "body", "state"} {
+				if v, ok := in[key]; ok {
+					pr[key] = v
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(pr)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeGitea) close(number int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prs[number-1]["state"] = "closed"
+}
+
+func (f *fakeGitea) state(number int) interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.prs[number-1]["state"]
+}
+
+// listTestRepositoryBranches returns the names of the branches of the repository at url.
+func listTestRepositoryBranches(t *testing.T, url string) []string {
+	t.Helper()
+
+	remote := gogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{url}})
+	refs, err := remote.List(&gogit.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var branches []string
+	for _, ref := range refs {
+		if ref.Name().IsBranch() {
+			branches = append(branches, ref.Name().Short())
+		}
+	}
+	return branches
+}
+
+func TestPullRequestStrategy_ClosedPullRequests(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	gitea := &fakeGitea{}
+	server := httptest.NewServer(gitea)
+	defer server.Close()
+
+	sourceURL := newTestRepository(t, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.1.0\n",
+	})
+	targetURL := newTestRepository(t, map[string]string{
+		"envs/prod/app-version/version.yaml": "version: 1.0.0\n",
+	})
+
+	promotion := &promotionsv1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod", Namespace: "default", Generation: 1},
+		Spec: promotionsv1alpha1.PromotionSpec{
+			Copy: []promotionsv1alpha1.CopyOperation{
+				{Name: "Application Version", Source: "app-version", Target: "app-version"},
+			},
+			Strategy:       promotionsv1alpha1.PromotionStrategyPullRequest,
+			OnSourceChange: promotionsv1alpha1.OnSourceChangeUpdate,
+		},
+	}
+	source := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/dev", Source: promotionsv1alpha1.Source{URL: sourceURL}},
+	}
+	target := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.EnvironmentSpec{
+			Path:               "envs/prod",
+			Source:             promotionsv1alpha1.Source{URL: targetURL},
+			GitProvider:        promotionsv1alpha1.GitProviderGitea,
+			GitProviderBaseURL: server.URL,
+		},
+	}
+
+	promote := func() {
+		strategy := &PullRequestStrategy{}
+		g.Expect(strategy.Promote(ctx, newTestPromotionRun(t, promotion, source, target))).To(Succeed())
+	}
+	closed := func() bool {
+		return apimeta.IsStatusConditionTrue(promotion.Status.Conditions, promotionsv1alpha1.PullRequestClosedCondition)
+	}
+
+	// A pull request is opened for the changes.
+	promote()
+	g.Expect(promotion.Status.LastPullRequestNumber).To(Equal(1))
+	g.Expect(promotion.Status.LastPullRequestState).To(Equal(promotionsv1alpha1.PullRequestStateOpen))
+	g.Expect(listTestRepositoryBranches(t, targetURL)).To(HaveLen(2))
+	g.Expect(gitea.prs[0]["body"]).To(And(
+		ContainSubstring("initial commit (Test)"),
+		ContainSubstring("**Application Version**\n- `envs/prod/app-version/version.yaml`"),
+	))
+
+	// A pull request closed without being merged is not reopened right away,
+	// and its branch is deleted.
+	gitea.close(1)
+	promote()
+	g.Expect(closed()).To(BeTrue())
+	g.Expect(promotion.Status.LastPullRequestState).To(Equal(promotionsv1alpha1.PullRequestStateClosed))
+	g.Expect(listTestRepositoryBranches(t, targetURL)).To(ConsistOf("master"))
+	promote()
+	g.Expect(gitea.prs).To(HaveLen(1))
+	g.Expect(promotionsv1alpha1.PromotionReadyMessage(*promotion)).To(ContainSubstring("closed without being merged"))
+
+	// A new pull request is opened once the cooldown elapsed.
+	promotion.Spec.ReopenAfter = &metav1.Duration{Duration: time.Nanosecond}
+	promote()
+	g.Expect(promotion.Status.LastPullRequestNumber).To(Equal(2))
+	g.Expect(closed()).To(BeFalse())
+
+	// With "recreate", the open pull request is superseded when the source environment moves on.
+	promotion.Spec.OnSourceChange = promotionsv1alpha1.OnSourceChangeRecreate
+	commitTestRepository(t, sourceURL, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.2.0\n",
+	})
+	promote()
+	g.Expect(gitea.state(2)).To(Equal("closed"))
+	g.Expect(gitea.comments).To(ConsistOf(ContainSubstring("Superseded")))
+	g.Expect(promotion.Status.LastPullRequestNumber).To(Equal(3))
+	g.Expect(promotion.Status.LastPullRequestState).To(Equal(promotionsv1alpha1.PullRequestStateOpen))
+	g.Expect(closed()).To(BeFalse())
+
+	// Without changes, the open pull request is kept.
+	promote()
+	g.Expect(gitea.prs).To(HaveLen(3))
+	g.Expect(gitea.state(3)).To(Equal("open"))
+}
+
+func TestPullRequestStrategy_Schedule(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	gitea := &fakeGitea{}
+	server := httptest.NewServer(gitea)
+	defer server.Close()
+
+	sourceURL := newTestRepository(t, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.1.0\n",
+	})
+	targetURL := newTestRepository(t, map[string]string{
+		"envs/prod/app-version/version.yaml": "version: 1.0.0\n",
+	})
+
+	promotion := &promotionsv1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod", Namespace: "default", Generation: 1},
+		Spec: promotionsv1alpha1.PromotionSpec{
+			Copy: []promotionsv1alpha1.CopyOperation{
+				{Name: "Application Version", Source: "app-version", Target: "app-version"},
+			},
+			Strategy:       promotionsv1alpha1.PromotionStrategyPullRequest,
+			OnSourceChange: promotionsv1alpha1.OnSourceChangeRecreate,
+		},
+	}
+	source := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/dev", Source: promotionsv1alpha1.Source{URL: sourceURL}},
+	}
+	target := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.EnvironmentSpec{
+			Path:               "envs/prod",
+			Source:             promotionsv1alpha1.Source{URL: targetURL},
+			GitProvider:        promotionsv1alpha1.GitProviderGitea,
+			GitProviderBaseURL: server.URL,
+		},
+	}
+
+	// promote promotes the latest commit of the source environment, blocked by block if not nil.
+	promote := func(block *schedule.Block) {
+		run := newTestPromotionRun(t, promotion, source, target)
+		run.Block = block
+		g.Expect((&PullRequestStrategy{}).Promote(ctx, run)).To(Succeed())
+	}
+	freeze := &schedule.Block{Reason: "by FreezeWindow year-end", Until: time.Now().Add(time.Hour)}
+
+	// No pull request is opened while blocked.
+	promote(freeze)
+	g.Expect(gitea.prs).To(BeEmpty())
+	g.Expect(listTestRepositoryBranches(t, targetURL)).To(ConsistOf("master"))
+	g.Expect(apimeta.IsStatusConditionFalse(promotion.Status.Conditions, promotionsv1alpha1.ReadyCondition)).To(BeTrue())
+
+	promote(nil)
+	g.Expect(gitea.prs).To(HaveLen(1))
+	branch := gitea.prs[0]["head"].(map[string]string)["ref"]
+
+	// The open pull request is not superseded while blocked.
+	commitTestRepository(t, sourceURL, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.2.0\n",
+	})
+	promote(freeze)
+	g.Expect(gitea.prs).To(HaveLen(1))
+	g.Expect(gitea.state(1)).To(Equal("open"))
+	g.Expect(listTestRepositoryBranches(t, targetURL)).To(ContainElement(branch))
+	g.Expect(gitea.comments).To(BeEmpty())
+
+	promote(nil)
+	g.Expect(gitea.state(1)).To(Equal("closed"))
+	g.Expect(gitea.prs).To(HaveLen(2))
+}
+
+func TestPullRequestStrategy_Approval(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	gitea := &fakeGitea{}
+	server := httptest.NewServer(gitea)
+	defer server.Close()
+
+	sourceURL := newTestRepository(t, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.1.0\n",
+	})
+	targetURL := newTestRepository(t, map[string]string{
+		"envs/prod/app-version/version.yaml": "version: 1.0.0\n",
+	})
+
+	promotion := &promotionsv1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod", Namespace: "default", Generation: 1},
+		Spec: promotionsv1alpha1.PromotionSpec{
+			Copy: []promotionsv1alpha1.CopyOperation{
+				{Name: "Application Version", Source: "app-version", Target: "app-version"},
+			},
+			Strategy:       promotionsv1alpha1.PromotionStrategyPullRequest,
+			OnSourceChange: promotionsv1alpha1.OnSourceChangeUpdate,
+			Approval:       &promotionsv1alpha1.ApprovalPolicy{},
+		},
+	}
+	source := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/dev", Source: promotionsv1alpha1.Source{URL: sourceURL}},
+	}
+	target := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.EnvironmentSpec{
+			Path:               "envs/prod",
+			Source:             promotionsv1alpha1.Source{URL: targetURL},
+			GitProvider:        promotionsv1alpha1.GitProviderGitea,
+			GitProviderBaseURL: server.URL,
+		},
+	}
+
+	// promote promotes the latest commit of the source environment, approving it if approved is true.
+	promote := func(approved bool) {
+		run := newTestPromotionRun(t, promotion, source, target)
+		if approved {
+			run.Approval = &promotionsv1alpha1.PromotionApproval{Spec: promotionsv1alpha1.PromotionApprovalSpec{
+				SourceCommitHash: run.SourceEnvironmentLatestCommit.Hash.String(),
+				ApprovedBy:       "alice@example.com",
+			}}
+		}
+		g.Expect((&PullRequestStrategy{}).Promote(ctx, run)).To(Succeed())
+	}
+
+	// No pull request is opened until the changes are approved.
+	promote(false)
+	g.Expect(gitea.prs).To(BeEmpty())
+	g.Expect(listTestRepositoryBranches(t, targetURL)).To(ConsistOf("master"))
+	g.Expect(apimeta.IsStatusConditionFalse(promotion.Status.Conditions, promotionsv1alpha1.ApprovedCondition)).To(BeTrue())
+
+	promote(true)
+	g.Expect(gitea.prs).To(HaveLen(1))
+	g.Expect(promotion.Status.ApprovedBy).To(Equal("alice@example.com"))
+
+	// A new commit of the source environment is not pushed to the open pull request until it is approved.
+	commitTestRepository(t, sourceURL, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.2.0\n",
+	})
+	branch := gitea.prs[0]["head"].(map[string]string)["ref"]
+	promote(false)
+	g.Expect(readTestRepositoryFile(t, targetURL, branch, "envs/prod/app-version/version.yaml")).To(Equal("version: 1.1.0\n"))
+	promote(true)
+	g.Expect(readTestRepositoryFile(t, targetURL, branch, "envs/prod/app-version/version.yaml")).To(Equal("version: 1.2.0\n"))
+	g.Expect(gitea.prs).To(HaveLen(1))
+
+	// The approved pull request is only superseded once the new commit is approved.
+	promotion.Spec.OnSourceChange = promotionsv1alpha1.OnSourceChangeRecreate
+	commitTestRepository(t, sourceURL, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.3.0\n",
+	})
+	promote(false)
+	g.Expect(gitea.prs).To(HaveLen(1))
+	g.Expect(gitea.state(1)).To(Equal("open"))
+	g.Expect(listTestRepositoryBranches(t, targetURL)).To(ContainElement(branch))
+	promote(true)
+	g.Expect(gitea.prs).To(HaveLen(2))
+	g.Expect(gitea.state(1)).To(Equal("closed"))
+	g.Expect(readTestRepositoryFile(t, targetURL, gitea.prs[1]["head"].(map[string]string)["ref"], "envs/prod/app-version/version.yaml")).To(Equal("version: 1.3.0\n"))
+}
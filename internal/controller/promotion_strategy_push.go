@@ -0,0 +1,68 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+// PushStrategy commits the copy operations directly to the branch of the
+// target environment, without going through a pull request.
+type PushStrategy struct{}
+
+func (s *PushStrategy) Promote(ctx context.Context, run *PromotionRun) error {
+	log := log.FromContext(ctx)
+	obj := run.Promotion
+
+	// The target environment repo is cloned with its branch checked out,
+	// so the commits are created on top of it.
+	promotedSubjects, err := run.CommitCopyOperations(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(promotedSubjects) == 0 {
+		*obj = promotionsv1alpha1.PromotionReady(*obj, promotionsv1alpha1.SucceededReason, "Source and target environments are in sync, nothing to promote.")
+		return run.MarkSynced()
+	}
+
+	if run.AwaitSchedule() {
+		log.Info("Blocked by schedule, not pushing promotion", "until", run.Block.Until)
+		return nil
+	}
+	if run.AwaitApproval() {
+		log.Info("Waiting for approval, not pushing promotion", "sourceCommit", run.SourceEnvironmentLatestCommit.Hash.String())
+		return nil
+	}
+
+	branch := run.TargetEnvironment.GetBranch()
+	if err := run.Push(ctx, branch); err != nil {
+		return err
+	}
+
+	log.Info("Pushed promotion to target environment", "branch", branch, "promoted", promotedSubjects)
+	*obj = promotionsv1alpha1.PromotionReady(*obj, promotionsv1alpha1.SucceededReason,
+		fmt.Sprintf("Pushed %s to branch %s", strings.Join(promotedSubjects, ", "), branch))
+
+	return run.MarkSynced()
+}
@@ -0,0 +1,612 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+func TestPushStrategy(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	sourceURL := newTestRepository(t, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.1.0\n",
+	})
+	targetURL := newTestRepository(t, map[string]string{
+		"envs/prod/app-version/version.yaml": "version: 1.0.0\n",
+		"envs/prod/settings.yaml":            "replicas: 3\n",
+	})
+
+	promotion := &promotionsv1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.PromotionSpec{
+			Copy: []promotionsv1alpha1.CopyOperation{
+				{Name: "Application Version", Source: "app-version", Target: "app-version"},
+			},
+			Strategy: promotionsv1alpha1.PromotionStrategyPush,
+		},
+	}
+	source := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec: promotionsv1alpha1.EnvironmentSpec{
+			Path:   "envs/dev",
+			Source: promotionsv1alpha1.Source{URL: sourceURL},
+		},
+	}
+	target := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.EnvironmentSpec{
+			Path:   "envs/prod",
+			Source: promotionsv1alpha1.Source{URL: targetURL},
+		},
+	}
+
+	promote := func() {
+		strategy, err := NewPromotionStrategy(nil, promotion.Spec.Strategy)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(strategy.Promote(ctx, newTestPromotionRun(t, promotion, source, target))).To(Succeed())
+	}
+
+	promote()
+	g.Expect(promotionsv1alpha1.PromotionReadyMessage(*promotion)).To(Equal("Pushed Application Version to branch master"))
+	g.Expect(readTestRepositoryFile(t, targetURL, "master", "envs/prod/app-version/version.yaml")).To(Equal("version: 1.1.0\n"))
+	g.Expect(readTestRepositoryFile(t, targetURL, "master", "envs/prod/settings.yaml")).To(Equal("replicas: 3\n"))
+	g.Expect(promotion.Status.LastSyncedSourceCommitHash).ToNot(BeEmpty())
+	g.Expect(promotion.Status.LastSyncedTargetCommitHash).ToNot(BeEmpty())
+
+	promote()
+	g.Expect(promotionsv1alpha1.PromotionReadyMessage(*promotion)).To(Equal("Source and target environments are in sync, nothing to promote."))
+}
+
+func TestPushStrategy_Approval(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(promotionsv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	sourceURL := newTestRepository(t, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.1.0\n",
+	})
+	targetURL := newTestRepository(t, map[string]string{
+		"envs/prod/app-version/version.yaml": "version: 1.0.0\n",
+	})
+
+	promotion := &promotionsv1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.PromotionSpec{
+			Copy: []promotionsv1alpha1.CopyOperation{
+				{Name: "Application Version", Source: "app-version", Target: "app-version"},
+			},
+			Strategy: promotionsv1alpha1.PromotionStrategyPush,
+			Approval: &promotionsv1alpha1.ApprovalPolicy{Approvers: []string{"alice@example.com"}},
+		},
+	}
+	source := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/dev", Source: promotionsv1alpha1.Source{URL: sourceURL}},
+	}
+	target := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/prod", Source: promotionsv1alpha1.Source{URL: targetURL}},
+	}
+	newApproval := func(name, approvedBy, sourceCommit string) *promotionsv1alpha1.PromotionApproval {
+		return &promotionsv1alpha1.PromotionApproval{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: promotionsv1alpha1.PromotionApprovalSpec{
+				PromotionRef:     corev1.LocalObjectReference{Name: promotion.Name},
+				SourceCommitHash: sourceCommit,
+				ApprovedBy:       approvedBy,
+			},
+		}
+	}
+	approvedCondition := func() *metav1.Condition {
+		return apimeta.FindStatusCondition(promotion.Status.Conditions, promotionsv1alpha1.ApprovedCondition)
+	}
+	// promote promotes the latest commit of the source environment,
+	// with the approvals accepted by the promotion.
+	promote := func(approvals ...client.Object) {
+		run := newTestPromotionRun(t, promotion, source, target)
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(approvals...).Build()
+		approval, err := GetPromotionApproval(ctx, c, promotion, run.SourceEnvironmentLatestCommit.Hash.String())
+		g.Expect(err).ToNot(HaveOccurred())
+		run.Approval = approval
+		g.Expect((&PushStrategy{}).Promote(ctx, run)).To(Succeed())
+	}
+
+	firstCommit := headTestRepositoryCommit(t, sourceURL, "master").Hash.String()
+
+	// Nothing is pushed without an approval of the commit by an approver.
+	promote(
+		newApproval("by-bob", "bob@example.com", firstCommit),
+		newApproval("other-commit", "alice@example.com", strings.Repeat("a", 40)),
+	)
+	g.Expect(readTestRepositoryFile(t, targetURL, "master", "envs/prod/app-version/version.yaml")).To(Equal("version: 1.0.0\n"))
+	g.Expect(approvedCondition().Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(approvedCondition().Message).To(ContainSubstring(firstCommit))
+	g.Expect(promotion.IsSynced()).To(BeFalse())
+
+	// The approval of an approver releases the promotion, and is recorded.
+	approval := newApproval("by-alice", "alice@example.com", firstCommit)
+	promote(approval)
+	g.Expect(readTestRepositoryFile(t, targetURL, "master", "envs/prod/app-version/version.yaml")).To(Equal("version: 1.1.0\n"))
+	g.Expect(approvedCondition().Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(promotion.Status.ApprovedSourceCommitHash).To(Equal(firstCommit))
+	g.Expect(promotion.Status.ApprovedBy).To(Equal("alice@example.com"))
+
+	// A new commit of the source environment invalidates the approval.
+	secondCommit := commitTestRepository(t, sourceURL, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.2.0\n",
+	})
+	promote(approval)
+	g.Expect(readTestRepositoryFile(t, targetURL, "master", "envs/prod/app-version/version.yaml")).To(Equal("version: 1.1.0\n"))
+	g.Expect(approvedCondition().Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(approvedCondition().Message).To(ContainSubstring(secondCommit))
+
+	// Any user's approval is accepted without approvers.
+	promotion.Spec.Approval.Approvers = nil
+	promote(approval, newApproval("by-bob-again", "bob@example.com", secondCommit))
+	g.Expect(readTestRepositoryFile(t, targetURL, "master", "envs/prod/app-version/version.yaml")).To(Equal("version: 1.2.0\n"))
+	g.Expect(promotion.Status.ApprovedSourceCommitHash).To(Equal(secondCommit))
+	g.Expect(promotion.Status.ApprovedBy).To(Equal("bob@example.com"))
+
+	// Approvals are ignored without the webhooks recording their approvers.
+	thirdCommit := commitTestRepository(t, sourceURL, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.3.0\n",
+	})
+	run := newTestPromotionRun(t, promotion, source, target)
+	run.Approval = newApproval("forged", "alice@example.com", thirdCommit)
+	run.IgnoreApprovals = true
+	g.Expect((&PushStrategy{}).Promote(ctx, run)).To(Succeed())
+	g.Expect(readTestRepositoryFile(t, targetURL, "master", "envs/prod/app-version/version.yaml")).To(Equal("version: 1.2.0\n"))
+	g.Expect(approvedCondition().Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(approvedCondition().Message).To(ContainSubstring("webhooks"))
+}
+
+func TestPushStrategy_Schedule(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	sourceURL := newTestRepository(t, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.1.0\n",
+	})
+	targetURL := newTestRepository(t, map[string]string{
+		"envs/prod/app-version/version.yaml": "version: 1.0.0\n",
+	})
+
+	promotion := &promotionsv1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.PromotionSpec{
+			Copy: []promotionsv1alpha1.CopyOperation{
+				{Name: "Application Version", Source: "app-version", Target: "app-version"},
+			},
+			Strategy: promotionsv1alpha1.PromotionStrategyPush,
+			Schedule: &promotionsv1alpha1.PromotionSchedule{
+				TimeZone: "Europe/Vienna",
+				Allow: []promotionsv1alpha1.ScheduleWindow{
+					{Cron: "0 8 * * 1-5", Duration: metav1.Duration{Duration: 10 * time.Hour}},
+				},
+			},
+		},
+	}
+	source := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/dev", Source: promotionsv1alpha1.Source{URL: sourceURL}},
+	}
+	target := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/prod", Source: promotionsv1alpha1.Source{URL: targetURL}},
+	}
+	scheme := runtime.NewScheme()
+	g.Expect(promotionsv1alpha1.AddToScheme(scheme)).To(Succeed())
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	// promote promotes the latest commit of the source environment at now.
+	promote := func(now time.Time) {
+		run := newTestPromotionRun(t, promotion, source, target)
+		block, err := GetScheduleBlock(ctx, c, promotion, now)
+		g.Expect(err).ToNot(HaveOccurred())
+		SetScheduleBlock(promotion, block)
+		run.Block = block
+		g.Expect((&PushStrategy{}).Promote(ctx, run)).To(Succeed())
+	}
+
+	// Nothing is pushed on a Saturday, until Monday morning.
+	promote(time.Date(2023, time.March, 18, 10, 0, 0, 0, time.UTC))
+	g.Expect(readTestRepositoryFile(t, targetURL, "master", "envs/prod/app-version/version.yaml")).To(Equal("version: 1.0.0\n"))
+	g.Expect(apimeta.IsStatusConditionFalse(promotion.Status.Conditions, promotionsv1alpha1.ReadyCondition)).To(BeTrue())
+	g.Expect(apimeta.IsStatusConditionTrue(promotion.Status.Conditions, promotionsv1alpha1.BlockedCondition)).To(BeTrue())
+	g.Expect(promotion.Status.BlockedUntil.Time.Equal(time.Date(2023, time.March, 20, 7, 0, 0, 0, time.UTC))).To(BeTrue())
+
+	// The changes are pushed once the allow window opens.
+	promote(time.Date(2023, time.March, 20, 7, 0, 0, 0, time.UTC))
+	g.Expect(readTestRepositoryFile(t, targetURL, "master", "envs/prod/app-version/version.yaml")).To(Equal("version: 1.1.0\n"))
+	g.Expect(apimeta.FindStatusCondition(promotion.Status.Conditions, promotionsv1alpha1.BlockedCondition)).To(BeNil())
+	g.Expect(promotion.Status.BlockedUntil).To(BeNil())
+}
+
+func TestNewPromotionStrategy(t *testing.T) {
+	g := NewWithT(t)
+
+	s, err := NewPromotionStrategy(nil, promotionsv1alpha1.PromotionStrategyPullRequest)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(s).To(BeAssignableToTypeOf(&PullRequestStrategy{}))
+
+	s, err = NewPromotionStrategy(nil, promotionsv1alpha1.PromotionStrategyPush)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(s).To(BeAssignableToTypeOf(&PushStrategy{}))
+
+	_, err = NewPromotionStrategy(nil, "carrier-pigeon")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestPushStrategy_Prune(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	sourceURL := newTestRepository(t, map[string]string{
+		"envs/dev/app/deployment.yaml": "kind: Deployment\n",
+	})
+	targetURL := newTestRepository(t, map[string]string{
+		"envs/prod/app/deployment.yaml":      "kind: Deployment\n",
+		"envs/prod/app/removed/service.yaml": "kind: Service\n",
+		"envs/prod/app/secrets/db.yaml":      "kind: Secret\n",
+		"envs/prod/settings.yaml":            "replicas: 3\n",
+	})
+
+	promotion := &promotionsv1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.PromotionSpec{
+			Copy: []promotionsv1alpha1.CopyOperation{
+				{Name: "Application", Source: "app", Target: "app", Prune: true, Protect: []string{"secrets/**"}},
+			},
+			Strategy: promotionsv1alpha1.PromotionStrategyPush,
+		},
+	}
+	source := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/dev", Source: promotionsv1alpha1.Source{URL: sourceURL}},
+	}
+	target := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/prod", Source: promotionsv1alpha1.Source{URL: targetURL}},
+	}
+
+	g.Expect((&PushStrategy{}).Promote(ctx, newTestPromotionRun(t, promotion, source, target))).To(Succeed())
+	g.Expect(listTestRepositoryFiles(t, targetURL, "master")).To(ConsistOf(
+		"envs/prod/app/deployment.yaml",
+		"envs/prod/app/secrets/db.yaml",
+		"envs/prod/settings.yaml",
+	))
+}
+
+func TestPushStrategy_IncludeExclude(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	sourceURL := newTestRepository(t, map[string]string{
+		"envs/dev/app/manifests/deployment.yaml":    "kind: Deployment\n",
+		"envs/dev/app/manifests/db/secrets-db.yaml": "kind: Secret\n",
+		"envs/dev/app/manifests/config-local.yaml":  "kind: ConfigMap\n",
+		"envs/dev/app/README.md":                    "# app\n",
+	})
+	targetURL := newTestRepository(t, map[string]string{
+		"envs/prod/settings.yaml": "replicas: 3\n",
+	})
+
+	promotion := &promotionsv1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.PromotionSpec{
+			Copy: []promotionsv1alpha1.CopyOperation{
+				{
+					Name:    "Application",
+					Source:  "app",
+					Target:  "app",
+					Include: []string{"manifests/**/*.yaml"},
+					Exclude: []string{"**/secrets-*.yaml", "**/*-local.yaml"},
+				},
+			},
+			Strategy: promotionsv1alpha1.PromotionStrategyPush,
+		},
+	}
+	source := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/dev", Source: promotionsv1alpha1.Source{URL: sourceURL}},
+	}
+	target := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/prod", Source: promotionsv1alpha1.Source{URL: targetURL}},
+	}
+
+	run := newTestPromotionRun(t, promotion, source, target)
+	// Unrelated changes in the target worktree are not committed.
+	g.Expect(os.WriteFile(filepath.Join(run.TargetEnvironmentPath, "envs/prod/settings.yaml"), []byte("replicas: 5\n"), 0644)).To(Succeed())
+
+	g.Expect((&PushStrategy{}).Promote(ctx, run)).To(Succeed())
+	g.Expect(listTestRepositoryFiles(t, targetURL, "master")).To(ConsistOf(
+		"envs/prod/app/manifests/deployment.yaml",
+		"envs/prod/settings.yaml",
+	))
+	g.Expect(readTestRepositoryFile(t, targetURL, "master", "envs/prod/settings.yaml")).To(Equal("replicas: 3\n"))
+}
+
+func TestPushStrategy_PruneIncludeExclude(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	sourceURL := newTestRepository(t, map[string]string{
+		"envs/dev/app/base/deployment.yaml": "kind: Deployment\n",
+	})
+	targetURL := newTestRepository(t, map[string]string{
+		"envs/prod/app/base/deployment.yaml":     "kind: Deployment\n",
+		"envs/prod/app/base/stale/service.yaml":  "kind: Service\n",
+		"envs/prod/app/base/README.md":           "# base\n",
+		"envs/prod/app/overlays/secrets-db.yaml": "kind: Secret\n",
+	})
+
+	promotion := &promotionsv1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.PromotionSpec{
+			Copy: []promotionsv1alpha1.CopyOperation{
+				{
+					Name:    "Application",
+					Source:  "app",
+					Target:  "app",
+					Prune:   true,
+					Include: []string{"**/*.yaml"},
+					Exclude: []string{"**/secrets-*.yaml"},
+				},
+			},
+			Strategy: promotionsv1alpha1.PromotionStrategyPush,
+		},
+	}
+	source := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/dev", Source: promotionsv1alpha1.Source{URL: sourceURL}},
+	}
+	target := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/prod", Source: promotionsv1alpha1.Source{URL: targetURL}},
+	}
+
+	// Stale files selected by the patterns are pruned in nested directories,
+	// files which are not selected are kept.
+	g.Expect((&PushStrategy{}).Promote(ctx, newTestPromotionRun(t, promotion, source, target))).To(Succeed())
+	g.Expect(listTestRepositoryFiles(t, targetURL, "master")).To(ConsistOf(
+		"envs/prod/app/base/deployment.yaml",
+		"envs/prod/app/base/README.md",
+		"envs/prod/app/overlays/secrets-db.yaml",
+	))
+}
+
+func TestPushStrategy_CommitAuthor(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	sourceURL := newTestRepository(t, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.1.0\n",
+	})
+	targetURL := newTestRepository(t, map[string]string{
+		"envs/prod/app-version/version.yaml": "version: 1.0.0\n",
+	})
+
+	promotion := &promotionsv1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.PromotionSpec{
+			Copy: []promotionsv1alpha1.CopyOperation{
+				{Name: "Application Version", Source: "app-version", Target: "app-version"},
+			},
+			Strategy: promotionsv1alpha1.PromotionStrategyPush,
+		},
+	}
+	source := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/dev", Source: promotionsv1alpha1.Source{URL: sourceURL}},
+	}
+	target := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.EnvironmentSpec{
+			Path:         "envs/prod",
+			Source:       promotionsv1alpha1.Source{URL: targetURL},
+			CommitAuthor: &promotionsv1alpha1.CommitAuthor{Name: "Platform Team", Email: "platform@example.com"},
+		},
+	}
+
+	promote := func() {
+		strategy := &PushStrategy{}
+		g.Expect(strategy.Promote(ctx, newTestPromotionRun(t, promotion, source, target))).To(Succeed())
+	}
+
+	// Commits are authored and committed by the commit author of the target environment.
+	promote()
+	commit := headTestRepositoryCommit(t, targetURL, "master")
+	g.Expect(commit.Author.Name).To(Equal("Platform Team"))
+	g.Expect(commit.Author.Email).To(Equal("platform@example.com"))
+	g.Expect(commit.Committer.Name).To(Equal("Platform Team"))
+	g.Expect(commit.Message).ToNot(ContainSubstring("Co-authored-by"))
+
+	// The commit author of the promotion takes precedence, and the authors of
+	// the source commits are added as co-authors.
+	promotion.Spec.CommitAuthor = &promotionsv1alpha1.CommitAuthor{Name: "Release Bot", Email: "release@example.com"}
+	promotion.Spec.CoAuthoredBy = true
+	commitTestRepository(t, sourceURL, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.2.0\n",
+	})
+	promote()
+	commit = headTestRepositoryCommit(t, targetURL, "master")
+	g.Expect(commit.Author.Email).To(Equal("release@example.com"))
+	g.Expect(commit.Committer.Email).To(Equal("release@example.com"))
+	g.Expect(commit.Message).To(HaveSuffix("\n\nCo-authored-by: Test <test@example.com>\n"))
+}
+
+func TestPushStrategy_SigningKey(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	// An OpenPGP key for the target environment, and an SSH key for the promotion.
+	entity, err := openpgp.NewEntity("Promotion Bot", "", promotionsv1alpha1.DefaultCommitAuthorEmail, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	var privateKey, publicKey bytes.Buffer
+	w, err := armor.Encode(&privateKey, openpgp.PrivateKeyType, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entity.SerializePrivate(w, nil)).To(Succeed())
+	g.Expect(w.Close()).To(Succeed())
+	w, err = armor.Encode(&publicKey, openpgp.PublicKeyType, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entity.Serialize(w)).To(Succeed())
+	g.Expect(w.Close()).To(Succeed())
+
+	_, sshKey, err := ed25519.GenerateKey(rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	der, err := x509.MarshalPKCS8PrivateKey(sshKey)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "gpg", Namespace: "default"},
+			Data:       map[string][]byte{"signingKey": privateKey.Bytes()},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "ssh", Namespace: "default"},
+			Data:       map[string][]byte{"signingKey": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})},
+		},
+	).Build()
+
+	sourceURL := newTestRepository(t, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.1.0\n",
+	})
+	targetURL := newTestRepository(t, map[string]string{
+		"envs/prod/app-version/version.yaml": "version: 1.0.0\n",
+	})
+
+	promotion := &promotionsv1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.PromotionSpec{
+			Copy: []promotionsv1alpha1.CopyOperation{
+				{Name: "Application Version", Source: "app-version", Target: "app-version"},
+			},
+			Strategy: promotionsv1alpha1.PromotionStrategyPush,
+		},
+	}
+	source := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Path: "envs/dev", Source: promotionsv1alpha1.Source{URL: sourceURL}},
+	}
+	target := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec: promotionsv1alpha1.EnvironmentSpec{
+			Path:                "envs/prod",
+			Source:              promotionsv1alpha1.Source{URL: targetURL},
+			SigningKeySecretRef: &corev1.LocalObjectReference{Name: "gpg"},
+		},
+	}
+
+	// Commits are signed with the key of the target environment.
+	key, err := GetSigningKey(ctx, c, promotion, target)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(key.OpenPGP).ToNot(BeNil())
+	run := newTestPromotionRun(t, promotion, source, target)
+	run.SigningKey = key
+	g.Expect((&PushStrategy{}).Promote(ctx, run)).To(Succeed())
+
+	commit := headTestRepositoryCommit(t, targetURL, "master")
+	signer, err := commit.Verify(publicKey.String())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(signer.PrimaryKey.KeyId).To(Equal(entity.PrimaryKey.KeyId))
+
+	// The key of the promotion takes precedence.
+	promotion.Spec.SigningKeySecretRef = &corev1.LocalObjectReference{Name: "ssh"}
+	key, err = GetSigningKey(ctx, c, promotion, target)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(key.SSH).ToNot(BeNil())
+	commitTestRepository(t, sourceURL, map[string]string{
+		"envs/dev/app-version/version.yaml": "version: 1.2.0\n",
+	})
+	run = newTestPromotionRun(t, promotion, source, target)
+	run.SigningKey = key
+	g.Expect((&PushStrategy{}).Promote(ctx, run)).To(Succeed())
+	commit = headTestRepositoryCommit(t, targetURL, "master")
+	g.Expect(commit.PGPSignature).To(HavePrefix("-----BEGIN SSH SIGNATURE-----"))
+	g.Expect(readTestRepositoryFile(t, targetURL, "master", "envs/prod/app-version/version.yaml")).To(Equal("version: 1.2.0\n"))
+
+	// Without a reference, commits are not signed.
+	promotion.Spec.SigningKeySecretRef = nil
+	target.Spec.SigningKeySecretRef = nil
+	key, err = GetSigningKey(ctx, c, promotion, target)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(key).To(BeNil())
+}
+
+// newTestPromotionRun clones the source and target environments of the
+// promotion, and returns the PromotionRun for them.
+func newTestPromotionRun(t *testing.T, promotion *promotionsv1alpha1.Promotion, source, target *promotionsv1alpha1.Environment) *PromotionRun {
+	t.Helper()
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	sourceDir := t.TempDir()
+	sourceRepo, err := GitCloneEnvironment(ctx, nil, nil, false, source, sourceDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	targetDir := t.TempDir()
+	targetRepo, err := GitCloneEnvironment(ctx, nil, nil, false, target, targetDir)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	targetWorktree, err := targetRepo.Worktree()
+	g.Expect(err).ToNot(HaveOccurred())
+	head, err := sourceRepo.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	sourceCommit, err := sourceRepo.CommitObject(head.Hash())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	return &PromotionRun{
+		Promotion:                     promotion,
+		SourceEnvironment:             source,
+		TargetEnvironment:             target,
+		SourceEnvironmentRepo:         sourceRepo,
+		TargetEnvironmentRepo:         targetRepo,
+		TargetEnvironmentWorktree:     targetWorktree,
+		SourceEnvironmentPath:         sourceDir,
+		TargetEnvironmentPath:         targetDir,
+		SourceEnvironmentLatestCommit: sourceCommit,
+		TargetCloneURL:                target.Spec.Source.URL,
+	}
+}
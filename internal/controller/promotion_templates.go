@@ -0,0 +1,154 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/templates"
+)
+
+// DefaultCommitMessageTemplate is the template of the message of the commit
+// created for each copy operation.
+const DefaultCommitMessageTemplate = `chore: promote {{.CopyOperation.Name}} from {{.SourceEnv.Name}} to {{.TargetEnv.Name}}
+
+SHA in source environment: {{.SourceEnvironmentLatestCommit}}
+`
+
+// DefaultPullRequestTitleTemplate is the template of the title of pull requests.
+const DefaultPullRequestTitleTemplate = `chore: promote {{join ", " .PromotedSubjects}} from {{.SourceEnv.Name}} to {{.TargetEnv.Name}}`
+
+// DefaultPullRequestBodyTemplate is the template of the description of pull requests.
+const DefaultPullRequestBodyTemplate = `Promotes {{.SourceEnv.Name}} to {{.TargetEnv.Name}}.
+
+### Changelog
+
+{{if .FromCommit}}Commits of {{.SourceEnv.Name}} from {{code .FromCommit}} to {{code .ToCommit}}:{{else}}Commits of {{.SourceEnv.Name}} up to {{code .ToCommit}}:{{end}}
+
+{{range .Commits}}- {{if .URL}}[{{code .ShortHash}}]({{.URL}}){{else}}{{code .ShortHash}}{{end}} {{.Subject}} ({{.Author}})
+{{else}}- No new commits
+{{end}}{{if .OmittedCommits}}- ... and {{.OmittedCommits}}{{if .MoreOmittedCommits}}+{{end}} more
+{{end}}
+### Changed files
+{{range .CopyOperations}}
+**{{.Name}}**
+{{range .Files}}- {{code .}}
+{{end}}{{end}}`
+
+// TemplateData is the data the commit messages and pull requests of a
+// promotion are rendered with.
+type TemplateData struct {
+	Prom      *promotionsv1alpha1.Promotion
+	SourceEnv *promotionsv1alpha1.Environment
+	TargetEnv *promotionsv1alpha1.Environment
+
+	// SourceEnvironmentLatestCommit is the short hash of the commit of the
+	// source environment being promoted.
+	SourceEnvironmentLatestCommit string
+	// SourceCommit is the commit of the source environment being promoted.
+	SourceCommit ChangelogCommit
+
+	// CopyOperation is the copy operation being committed.
+	// It is only set when rendering commit messages.
+	CopyOperation promotionsv1alpha1.CopyOperation
+	// PromotedSubjects are the names of the copy operations which were committed.
+	// It is only set when rendering pull requests.
+	PromotedSubjects []string
+
+	// FromCommit is the short hash of the last promoted commit of the source
+	// environment, or empty if nothing was promoted yet, or the commit is not
+	// in the history of ToCommit.
+	FromCommit string
+	// ToCommit is the short hash of the commit of the source environment being promoted.
+	ToCommit string
+
+	// Commits are the commits of the source environment after FromCommit, up
+	// to and including ToCommit, newest first.
+	Commits []ChangelogCommit
+	// OmittedCommits is the number of commits not listed in Commits,
+	// as there are more than MaxChangelogCommits.
+	OmittedCommits int
+	// MoreOmittedCommits is set if more than OmittedCommits commits are
+	// omitted, as only part of the history was walked.
+	MoreOmittedCommits bool
+
+	// CopyOperations lists the files changed by the pull request per copy operation.
+	// It is only set when rendering pull requests.
+	CopyOperations []CopyOperationChanges
+}
+
+// templates returns the user-defined templates of the promotion of the data.
+func (data TemplateData) templates() promotionsv1alpha1.PromotionTemplates {
+	if data.Prom == nil || data.Prom.Spec.Templates == nil {
+		return promotionsv1alpha1.PromotionTemplates{}
+	}
+	return *data.Prom.Spec.Templates
+}
+
+// CommitMessage renders the message of the commit of data.CopyOperation,
+// with the template of the promotion, or DefaultCommitMessageTemplate.
+func CommitMessage(data TemplateData) (string, error) {
+	return renderTemplate("commitMessage", data.templates().CommitMessage, DefaultCommitMessageTemplate, data)
+}
+
+// PullRequestTitle renders the title of a pull request, with the template
+// of the promotion, or DefaultPullRequestTitleTemplate.
+func PullRequestTitle(data TemplateData) (string, error) {
+	return renderTemplate("prTitle", data.templates().PRTitle, DefaultPullRequestTitleTemplate, data)
+}
+
+// PullRequestBody renders the description of a pull request, with the
+// template of the promotion, or DefaultPullRequestBodyTemplate.
+func PullRequestBody(data TemplateData) (string, error) {
+	return renderTemplate("prBody", data.templates().PRBody, DefaultPullRequestBodyTemplate, data)
+}
+
+func renderTemplate(name string, text string, defaultText string, data TemplateData) (string, error) {
+	if text == "" {
+		text = defaultText
+	}
+	return templates.Render(name, text, data)
+}
+
+// TemplateData returns the data the templates of the run are rendered with,
+// including the changelog of the source environment since the last promotion.
+func (run *PromotionRun) TemplateData() (TemplateData, error) {
+	obj := run.Promotion
+
+	changelog, err := SourceChangelog(run.SourceEnvironment, run.SourceEnvironmentRepo,
+		obj.Status.LastPromotedSourceCommitHash, run.SourceEnvironmentLatestCommit)
+	if err != nil {
+		return TemplateData{}, err
+	}
+
+	shortHash := run.SourceEnvironmentLatestCommit.Hash.String()[0:7]
+	data := TemplateData{
+		Prom:                          obj,
+		SourceEnv:                     run.SourceEnvironment,
+		TargetEnv:                     run.TargetEnvironment,
+		SourceEnvironmentLatestCommit: shortHash,
+		SourceCommit:                  NewChangelogCommit(run.SourceEnvironment, run.SourceEnvironmentLatestCommit),
+		ToCommit:                      shortHash,
+		Commits:                       changelog.Commits,
+		OmittedCommits:                changelog.Omitted,
+		MoreOmittedCommits:            changelog.Truncated,
+	}
+	// The range only starts at the last promoted commit if it was found.
+	if from := obj.Status.LastPromotedSourceCommitHash; changelog.FromFound && len(from) >= 7 {
+		data.FromCommit = from[0:7]
+	}
+	return data, nil
+}
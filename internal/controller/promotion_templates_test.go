@@ -0,0 +1,74 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+func TestPromotionTemplates(t *testing.T) {
+	g := NewWithT(t)
+
+	data := TemplateData{
+		Prom:                          &promotionsv1alpha1.Promotion{ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod"}},
+		SourceEnv:                     &promotionsv1alpha1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "dev"}},
+		TargetEnv:                     &promotionsv1alpha1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "prod"}},
+		SourceEnvironmentLatestCommit: "3333333",
+		SourceCommit: ChangelogCommit{
+			ShortHash: "3333333",
+			Subject:   "Bump app to 1.2.0",
+			Message:   "Bump app to 1.2.0\n\nRefs: JIRA-42\n",
+			Author:    "Jane",
+		},
+		CopyOperation:    promotionsv1alpha1.CopyOperation{Name: "Application Version"},
+		PromotedSubjects: []string{"Application Version", "Replicas"},
+	}
+
+	// The built-in templates are used by default.
+	msg, err := CommitMessage(data)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(msg).To(Equal("chore: promote Application Version from dev to prod\n\nSHA in source environment: 3333333\n"))
+	title, err := PullRequestTitle(data)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(title).To(Equal("chore: promote Application Version, Replicas from dev to prod"))
+
+	// The templates of the promotion override the built-in ones.
+	data.Prom.Spec.Templates = &promotionsv1alpha1.PromotionTemplates{
+		CommitMessage: "{{.CopyOperation.Name | lower}}: {{.SourceCommit.Subject}}\n\n{{regexFind \"JIRA-[0-9]+\" .SourceCommit.Message}}",
+		PRTitle:       "[{{upper .TargetEnv.Name}}] {{.SourceCommit.Subject}} by {{.SourceCommit.Author}}",
+		PRBody:        "{{default \"no changes\" .Commits}}",
+	}
+	msg, err = CommitMessage(data)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(msg).To(Equal("application version: Bump app to 1.2.0\n\nJIRA-42"))
+	title, err = PullRequestTitle(data)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(title).To(Equal("[PROD] Bump app to 1.2.0 by Jane"))
+	body, err := PullRequestBody(data)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(body).To(Equal("no changes"))
+
+	// Templates referencing unknown fields fail to render.
+	data.Prom.Spec.Templates.PRTitle = "{{.Unknown}}"
+	_, err = PullRequestTitle(data)
+	g.Expect(err).To(HaveOccurred())
+}
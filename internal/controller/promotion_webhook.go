@@ -0,0 +1,175 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/templates"
+)
+
+// PromotionValidator validates Promotions. It lives next to the controller,
+// as the templates are checked by rendering them with TemplateData.
+type PromotionValidator struct{}
+
+// SetupWebhookWithManager sets up the validating webhook with the Manager.
+func (v *PromotionValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&promotionsv1alpha1.Promotion{}).
+		WithValidator(v).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-promotions-gitopsprom-io-v1alpha1-promotion,mutating=false,failurePolicy=fail,sideEffects=None,groups=promotions.gitopsprom.io,resources=promotions,verbs=create;update,versions=v1alpha1,name=vpromotion.kb.io,admissionReviewVersions=v1
+
+var _ admission.CustomValidator = &PromotionValidator{}
+
+// ValidateCreate implements admission.CustomValidator so a webhook will be registered for the type
+func (v *PromotionValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	return v.validate(obj)
+}
+
+// ValidateUpdate implements admission.CustomValidator so a webhook will be registered for the type
+func (v *PromotionValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	return v.validate(newObj)
+}
+
+// ValidateDelete implements admission.CustomValidator so a webhook will be registered for the type
+func (v *PromotionValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+func (v *PromotionValidator) validate(obj runtime.Object) error {
+	promotion, ok := obj.(*promotionsv1alpha1.Promotion)
+	if !ok {
+		return fmt.Errorf("expected a Promotion but got a %T", obj)
+	}
+	allErrs := validatePromotionTemplates(promotion)
+	allErrs = append(allErrs, validatePromotionSchedule(promotion)...)
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(promotionsv1alpha1.GroupVersion.WithKind("Promotion").GroupKind(), promotion.Name, allErrs)
+}
+
+// validatePromotionTemplates checks that the templates of the promotion
+// render with sample data, so they only use known functions and fields.
+func validatePromotionTemplates(promotion *promotionsv1alpha1.Promotion) field.ErrorList {
+	var allErrs field.ErrorList
+	if promotion.Spec.Templates == nil {
+		return allErrs
+	}
+
+	data := sampleTemplateData(promotion)
+	path := field.NewPath("spec", "templates")
+	for _, t := range []struct {
+		name string
+		text string
+	}{
+		{"commitMessage", promotion.Spec.Templates.CommitMessage},
+		{"prTitle", promotion.Spec.Templates.PRTitle},
+		{"prBody", promotion.Spec.Templates.PRBody},
+	} {
+		if _, err := templates.Render(t.name, t.text, data); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child(t.name), t.text, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+// sampleTemplateData returns TemplateData with every field set, as if a
+// commit of the source environment of the promotion was being promoted.
+func sampleTemplateData(promotion *promotionsv1alpha1.Promotion) TemplateData {
+	environment := func(ref string) *promotionsv1alpha1.Environment {
+		return &promotionsv1alpha1.Environment{
+			ObjectMeta: metav1.ObjectMeta{Name: ref, Namespace: promotion.Namespace},
+		}
+	}
+	var source, target string
+	if promotion.Spec.SourceEnvironmentRef != nil {
+		source = promotion.Spec.SourceEnvironmentRef.Name
+	}
+	if promotion.Spec.TargetEnvironmentRef != nil {
+		target = promotion.Spec.TargetEnvironmentRef.Name
+	}
+
+	hash := strings.Repeat("0123456789", 4)
+	commit := ChangelogCommit{
+		Hash:        hash,
+		ShortHash:   hash[0:7],
+		Subject:     "Sample commit",
+		Message:     "Sample commit\n\nSample description.\n",
+		Author:      "Sample Author",
+		AuthorEmail: "author@example.com",
+		Date:        time.Now(),
+	}
+	operation := promotionsv1alpha1.CopyOperation{Name: "Sample", Source: "sample", Target: "sample"}
+	if len(promotion.Spec.Copy) > 0 {
+		operation = promotion.Spec.Copy[0]
+	}
+
+	return TemplateData{
+		Prom:                          promotion,
+		SourceEnv:                     environment(source),
+		TargetEnv:                     environment(target),
+		SourceEnvironmentLatestCommit: commit.ShortHash,
+		SourceCommit:                  commit,
+		CopyOperation:                 operation,
+		PromotedSubjects:              []string{operation.Name},
+		FromCommit:                    commit.ShortHash,
+		ToCommit:                      commit.ShortHash,
+		Commits:                       []ChangelogCommit{commit},
+		CopyOperations:                []CopyOperationChanges{{Name: operation.Name, Files: []string{operation.Target}}},
+	}
+}
+
+// validatePromotionSchedule checks that the windows of the schedule of the
+// promotion have valid cron expressions, durations and time zones.
+func validatePromotionSchedule(promotion *promotionsv1alpha1.Promotion) field.ErrorList {
+	var allErrs field.ErrorList
+	if promotion.Spec.Schedule == nil {
+		return allErrs
+	}
+
+	path := field.NewPath("spec", "schedule")
+	allErrs = append(allErrs, validateScheduleWindows(path.Child("allow"), promotion.Spec.Schedule.Allow, promotion.Spec.Schedule.TimeZone)...)
+	allErrs = append(allErrs, validateScheduleWindows(path.Child("deny"), promotion.Spec.Schedule.Deny, promotion.Spec.Schedule.TimeZone)...)
+	return allErrs
+}
+
+// validateScheduleWindows checks that the windows have valid cron expressions,
+// durations and time zones, which default to timeZone.
+func validateScheduleWindows(path *field.Path, windows []promotionsv1alpha1.ScheduleWindow, timeZone string) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, w := range windows {
+		if _, err := NewScheduleWindow("", w, timeZone); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), w, err.Error()))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+func TestPromotionValidator_Templates(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates *promotionsv1alpha1.PromotionTemplates
+		wantErr   string
+	}{
+		{
+			name: "no templates",
+		},
+		{
+			name: "valid templates",
+			templates: &promotionsv1alpha1.PromotionTemplates{
+				CommitMessage: "chore: promote {{.CopyOperation.Name}}\n\n{{.SourceCommit.Message}}",
+				PRTitle:       "{{.SourceEnv.Name | upper}} to {{.TargetEnv.Name}}",
+				PRBody:        "{{range .Commits}}- {{trunc 50 .Subject}}\n{{end}}{{range .CopyOperations}}{{join \", \" .Files}}{{end}}",
+			},
+		},
+		{
+			name:      "syntax error",
+			templates: &promotionsv1alpha1.PromotionTemplates{PRTitle: "{{.SourceEnv.Name"},
+			wantErr:   "spec.templates.prTitle",
+		},
+		{
+			name:      "unknown function",
+			templates: &promotionsv1alpha1.PromotionTemplates{CommitMessage: "{{sha256 .SourceEnv.Name}}"},
+			wantErr:   "spec.templates.commitMessage",
+		},
+		{
+			name:      "unknown field",
+			templates: &promotionsv1alpha1.PromotionTemplates{PRBody: "{{range .Commits}}{{.Sha}}{{end}}"},
+			wantErr:   "spec.templates.prBody",
+		},
+		{
+			name:      "wrong argument type",
+			templates: &promotionsv1alpha1.PromotionTemplates{PRTitle: "{{trunc .SourceEnv.Name 10}}"},
+			wantErr:   "spec.templates.prTitle",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			v := &PromotionValidator{}
+			promotion := &promotionsv1alpha1.Promotion{
+				ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod"},
+				Spec: promotionsv1alpha1.PromotionSpec{
+					SourceEnvironmentRef: &corev1.LocalObjectReference{Name: "dev"},
+					TargetEnvironmentRef: &corev1.LocalObjectReference{Name: "prod"},
+					Templates:            tt.templates,
+				},
+			}
+			errs := []error{v.ValidateCreate(ctx, promotion), v.ValidateUpdate(ctx, promotion, promotion)}
+			for _, err := range errs {
+				if tt.wantErr == "" {
+					g.Expect(err).ToNot(HaveOccurred())
+					continue
+				}
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+			}
+		})
+	}
+}
+
+func TestPromotionValidator_Schedule(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	v := &PromotionValidator{}
+	promotion := &promotionsv1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-to-prod"},
+		Spec: promotionsv1alpha1.PromotionSpec{Schedule: &promotionsv1alpha1.PromotionSchedule{
+			TimeZone: "Europe/Vienna",
+			Allow:    []promotionsv1alpha1.ScheduleWindow{{Cron: "0 8 * * MON-FRI", Duration: metav1.Duration{Duration: 10 * time.Hour}}},
+			Deny:     []promotionsv1alpha1.ScheduleWindow{{Cron: "0 14 * * FRI", Duration: metav1.Duration{Duration: 10 * time.Hour}, TimeZone: "America/New_York"}},
+		}},
+	}
+	g.Expect(v.ValidateCreate(ctx, promotion)).To(Succeed())
+
+	promotion.Spec.Schedule.Allow[0].Cron = "0 8 * *"
+	promotion.Spec.Schedule.Deny[0].TimeZone = "Mars/Olympus_Mons"
+	err := v.ValidateUpdate(ctx, promotion, promotion)
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(err.Error()).To(And(ContainSubstring("spec.schedule.allow[0]"), ContainSubstring("spec.schedule.deny[0]")))
+}
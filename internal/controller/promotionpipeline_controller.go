@@ -0,0 +1,292 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/gitcache"
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/util"
+)
+
+// PipelineLabel is the label set on Promotions owned by a PromotionPipeline,
+// its value is the name of the PromotionPipeline.
+const PipelineLabel = "promotions.gitopsprom.io/pipeline"
+
+// PromotionPipelineReconciler reconciles a PromotionPipeline object
+type PromotionPipelineReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// GitCache is the cache the repositories are cloned from.
+	// Repositories are cloned from their remote if nil.
+	GitCache *gitcache.Cache
+
+	// RequireKnownHosts refuses to connect to SSH servers whose host key
+	// can't be verified against the known hosts of the secret of the Environment.
+	RequireKnownHosts bool
+}
+
+//+kubebuilder:rbac:groups=promotions.gitopsprom.io,resources=promotionpipelines,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=promotions.gitopsprom.io,resources=promotionpipelines/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=promotions.gitopsprom.io,resources=promotionpipelines/finalizers,verbs=update
+
+func (r *PromotionPipelineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	start := time.Now()
+
+	obj := &promotionsv1alpha1.PromotionPipeline{}
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// Run these functions after the reconcile loop
+	defer func() {
+		obj.Status.ObservedGeneration = obj.GetObjectMeta().GetGeneration()
+
+		if err := r.Status().Update(ctx, obj); err != nil {
+			log.Error(err, "Unable to update PromotionPipeline status")
+		}
+	}()
+
+	// The API requires at least two stages, but don't rely on it here.
+	if len(obj.Spec.Stages) == 0 {
+		*obj = promotionsv1alpha1.PromotionPipelineNotReady(*obj, promotionsv1alpha1.PromotionOperationFailedReason,
+			"PromotionPipeline has no stages")
+		return ctrl.Result{}, nil
+	}
+
+	// Get the environments of all stages
+	environments := make([]*promotionsv1alpha1.Environment, len(obj.Spec.Stages))
+	for i, stage := range obj.Spec.Stages {
+		environment := &promotionsv1alpha1.Environment{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: obj.Namespace, Name: stage.EnvironmentRef.Name}, environment); err != nil {
+			if apierrors.IsNotFound(err) {
+				// We get notified through the Environment watch once it exists.
+				*obj = promotionsv1alpha1.PromotionPipelineNotReady(*obj, promotionsv1alpha1.PromotionOperationFailedReason,
+					fmt.Sprintf("Environment %s not found", stage.EnvironmentRef.Name))
+				return ctrl.Result{}, nil
+			}
+			return ctrl.Result{}, err
+		}
+		environments[i] = environment
+	}
+
+	stages := []promotionsv1alpha1.PromotionPipelineStageStatus{{
+		Environment:        environments[0].Name,
+		ObservedCommitHash: environments[0].Status.ObservedCommitHash,
+		Phase:              promotionsv1alpha1.StagePhaseSource,
+	}}
+	owned := map[string]bool{}
+	var previous *promotionsv1alpha1.Promotion
+	var pending []string
+
+	// Create or update a Promotion for each hop between two consecutive stages.
+	for i := 1; i < len(obj.Spec.Stages); i++ {
+		stage := obj.Spec.Stages[i]
+		source, target := environments[i-1], environments[i]
+		open := true
+		if previous != nil {
+			var err error
+			if open, err = r.isHopOpen(ctx, obj, previous, environments[i-2], source); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		promotion := &promotionsv1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%s-to-%s", obj.Name, source.Name, target.Name),
+				Namespace: obj.Namespace,
+			},
+		}
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, promotion, func() error {
+			if promotion.Labels == nil {
+				promotion.Labels = map[string]string{}
+			}
+			promotion.Labels[PipelineLabel] = obj.Name
+			promotion.Spec.SourceEnvironmentRef = &corev1.LocalObjectReference{Name: source.Name}
+			promotion.Spec.TargetEnvironmentRef = &corev1.LocalObjectReference{Name: target.Name}
+			promotion.Spec.Copy = obj.Spec.Copy
+			if len(stage.Copy) > 0 {
+				promotion.Spec.Copy = stage.Copy
+			}
+			promotion.Spec.Strategy = obj.Spec.Strategy
+			if stage.Strategy != "" {
+				promotion.Spec.Strategy = stage.Strategy
+			}
+			promotion.Spec.Suspend = !open
+			return controllerutil.SetControllerReference(obj, promotion, r.Scheme)
+		}); err != nil {
+			return ctrl.Result{}, err
+		}
+		owned[promotion.Name] = true
+
+		phase := promotionsv1alpha1.StagePhasePromoting
+		if !open {
+			phase = promotionsv1alpha1.StagePhaseWaiting
+		} else if promotion.IsSynced() {
+			phase = promotionsv1alpha1.StagePhaseSynced
+		}
+		if phase != promotionsv1alpha1.StagePhaseSynced {
+			pending = append(pending, target.Name)
+		}
+
+		stages = append(stages, promotionsv1alpha1.PromotionPipelineStageStatus{
+			Environment:                target.Name,
+			ObservedCommitHash:         target.Status.ObservedCommitHash,
+			Promotion:                  promotion.Name,
+			Phase:                      phase,
+			LastSyncedSourceCommitHash: promotion.Status.LastSyncedSourceCommitHash,
+			PullRequestURL:             promotion.Status.LastPullRequestURL,
+		})
+		previous = promotion
+	}
+	obj.Status.Stages = stages
+
+	// Delete the Promotions of hops which are no longer part of the pipeline
+	promotions := &promotionsv1alpha1.PromotionList{}
+	if err := r.List(ctx, promotions, client.InNamespace(obj.Namespace), client.MatchingLabels{PipelineLabel: obj.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+	for i := range promotions.Items {
+		promotion := &promotions.Items[i]
+		if owned[promotion.Name] || !metav1.IsControlledBy(promotion, obj) {
+			continue
+		}
+		log.Info("Deleting Promotion which is no longer part of the pipeline", "promotion", promotion.Name)
+		if err := r.Delete(ctx, promotion); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if len(pending) == 0 {
+		*obj = promotionsv1alpha1.PromotionPipelineReady(*obj, promotionsv1alpha1.SucceededReason, "All stages are in sync.")
+	} else {
+		*obj = promotionsv1alpha1.PromotionPipelineReady(*obj, promotionsv1alpha1.SucceededReason,
+			fmt.Sprintf("Waiting for stages to get in sync: %v", pending))
+	}
+
+	end := time.Now()
+	log.Info("Reconciled PromotionPipeline successfully", "duration", end.Sub(start))
+
+	return ctrl.Result{}, nil
+}
+
+// isHopOpen tells whether changes may be promoted from source, which is the
+// target of the previous hop. This is the case once the previous hop is in sync
+// (e.g. its pull request has been merged) for the current commit of its source
+// environment, and source has observed the commit which contains the promoted
+// changes, or a later commit.
+func (r *PromotionPipelineReconciler) isHopOpen(ctx context.Context, pipeline *promotionsv1alpha1.PromotionPipeline,
+	previous *promotionsv1alpha1.Promotion, previousSource, source *promotionsv1alpha1.Environment) (bool, error) {
+	if previous.Spec.Suspend || !previous.IsSynced() ||
+		previous.Status.LastSyncedSourceCommitHash != previousSource.Status.ObservedCommitHash {
+		return false, nil
+	}
+
+	synced, observed := previous.Status.LastSyncedTargetCommitHash, source.Status.ObservedCommitHash
+	if synced == "" || observed == "" {
+		return false, nil
+	}
+	if synced == observed {
+		return true, nil
+	}
+	return r.isAncestor(ctx, pipeline, source, synced)
+}
+
+// isAncestor tells whether commit is an ancestor of the commit observed by the
+// Environment, by cloning the branch of the Environment.
+func (r *PromotionPipelineReconciler) isAncestor(ctx context.Context, pipeline *promotionsv1alpha1.PromotionPipeline,
+	environment *promotionsv1alpha1.Environment, commit string) (bool, error) {
+	tmpDir, err := util.TempDirForObj("", pipeline)
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	repo, err := GitCloneEnvironment(ctx, r.Client, r.GitCache, r.RequireKnownHosts, environment, tmpDir)
+	if err != nil {
+		return false, err
+	}
+	// Either commit may be missing from the branch, e.g. if the pushed commit
+	// hasn't been fetched yet or the branch has been rewritten.
+	observed, err := repo.CommitObject(plumbing.NewHash(environment.Status.ObservedCommitHash))
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	ancestor, err := repo.CommitObject(plumbing.NewHash(commit))
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return ancestor.IsAncestor(observed)
+}
+
+// requestsForEnvironment returns a reconcile request for each PromotionPipeline
+// in the namespace of the Environment which has a stage referring to it.
+func (r *PromotionPipelineReconciler) requestsForEnvironment(obj client.Object) []reconcile.Request {
+	pipelines := &promotionsv1alpha1.PromotionPipelineList{}
+	if err := r.List(context.Background(), pipelines, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, pipeline := range pipelines.Items {
+		for _, stage := range pipeline.Spec.Stages {
+			if stage.EnvironmentRef.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: pipeline.Namespace, Name: pipeline.Name},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PromotionPipelineReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&promotionsv1alpha1.PromotionPipeline{}).
+		Owns(&promotionsv1alpha1.Promotion{}).
+		Watches(&source.Kind{Type: &promotionsv1alpha1.Environment{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForEnvironment)).
+		Complete(r)
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+func TestPromotionPipelineReconciler(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(promotionsv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	environment := func(name, url, commit string) *promotionsv1alpha1.Environment {
+		return &promotionsv1alpha1.Environment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       promotionsv1alpha1.EnvironmentSpec{Source: promotionsv1alpha1.Source{URL: url}},
+			Status:     promotionsv1alpha1.EnvironmentStatus{ObservedCommitHash: commit},
+		}
+	}
+	stagingURL := newTestRepository(t, map[string]string{"app-version/version.yaml": "version: 1.0.0\n"})
+	s1 := headTestRepositoryCommit(t, stagingURL, "master").Hash.String()
+	pipeline := &promotionsv1alpha1.PromotionPipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: promotionsv1alpha1.PromotionPipelineSpec{
+			Stages: []promotionsv1alpha1.PromotionPipelineStage{
+				{EnvironmentRef: corev1.LocalObjectReference{Name: "dev"}},
+				{EnvironmentRef: corev1.LocalObjectReference{Name: "staging"}},
+				{EnvironmentRef: corev1.LocalObjectReference{Name: "prod"}, Strategy: promotionsv1alpha1.PromotionStrategyPush},
+			},
+			Copy: []promotionsv1alpha1.CopyOperation{
+				{Name: "Application Version", Source: "app-version", Target: "app-version"},
+			},
+			Strategy: promotionsv1alpha1.PromotionStrategyPullRequest,
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(pipeline, environment("dev", "", "d1"), environment("staging", stagingURL, s1), environment("prod", "", "p1")).
+		Build()
+	r := &PromotionPipelineReconciler{Client: c, Scheme: scheme}
+
+	reconcile := func() *promotionsv1alpha1.PromotionPipeline {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipeline)})
+		g.Expect(err).ToNot(HaveOccurred())
+		obj := &promotionsv1alpha1.PromotionPipeline{}
+		g.Expect(c.Get(ctx, client.ObjectKeyFromObject(pipeline), obj)).To(Succeed())
+		return obj
+	}
+	getPromotion := func(name string) *promotionsv1alpha1.Promotion {
+		promotion := &promotionsv1alpha1.Promotion{}
+		g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, promotion)).To(Succeed())
+		return promotion
+	}
+
+	// The first hop may start right away, the second one waits for it.
+	obj := reconcile()
+	first := getPromotion("app-dev-to-staging")
+	g.Expect(first.Spec.Suspend).To(BeFalse())
+	g.Expect(first.Spec.Strategy).To(Equal(promotionsv1alpha1.PromotionStrategyPullRequest))
+	g.Expect(metav1.IsControlledBy(first, obj)).To(BeTrue())
+	second := getPromotion("app-staging-to-prod")
+	g.Expect(second.Spec.Suspend).To(BeTrue())
+	g.Expect(second.Spec.Strategy).To(Equal(promotionsv1alpha1.PromotionStrategyPush))
+	g.Expect(obj.Status.Stages).To(HaveLen(3))
+	g.Expect(obj.Status.Stages[0].Phase).To(Equal(promotionsv1alpha1.StagePhaseSource))
+	g.Expect(obj.Status.Stages[1].Phase).To(Equal(promotionsv1alpha1.StagePhasePromoting))
+	g.Expect(obj.Status.Stages[2].Phase).To(Equal(promotionsv1alpha1.StagePhaseWaiting))
+
+	// The pull request into staging got merged, but staging hasn't observed it yet.
+	s2 := commitTestRepository(t, stagingURL, map[string]string{"app-version/version.yaml": "version: 1.1.0\n"})
+	first.Status.ObservedSourceCommitHash = "d1"
+	*first = promotionsv1alpha1.PromotionSynced(*first, "d1", s2)
+	g.Expect(c.Status().Update(ctx, first)).To(Succeed())
+	obj = reconcile()
+	g.Expect(obj.Status.Stages[1].Phase).To(Equal(promotionsv1alpha1.StagePhaseSynced))
+	g.Expect(obj.Status.Stages[2].Phase).To(Equal(promotionsv1alpha1.StagePhaseWaiting))
+	g.Expect(getPromotion("app-staging-to-prod").Spec.Suspend).To(BeTrue())
+
+	// Once staging contains the promoted change, the next hop starts.
+	staging := &promotionsv1alpha1.Environment{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "staging"}, staging)).To(Succeed())
+	staging.Status.ObservedCommitHash = s2
+	g.Expect(c.Status().Update(ctx, staging)).To(Succeed())
+	obj = reconcile()
+	g.Expect(obj.Status.Stages[2].Phase).To(Equal(promotionsv1alpha1.StagePhasePromoting))
+	g.Expect(getPromotion("app-staging-to-prod").Spec.Suspend).To(BeFalse())
+
+	// Later commits to staging keep the next hop open.
+	staging.Status.ObservedCommitHash = commitTestRepository(t, stagingURL, map[string]string{"README.md": "staging\n"})
+	g.Expect(c.Status().Update(ctx, staging)).To(Succeed())
+	obj = reconcile()
+	g.Expect(obj.Status.Stages[2].Phase).To(Equal(promotionsv1alpha1.StagePhasePromoting))
+	g.Expect(getPromotion("app-staging-to-prod").Spec.Suspend).To(BeFalse())
+
+	// A new commit in dev closes it again until it has been promoted to staging.
+	dev := &promotionsv1alpha1.Environment{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "dev"}, dev)).To(Succeed())
+	dev.Status.ObservedCommitHash = "d2"
+	g.Expect(c.Status().Update(ctx, dev)).To(Succeed())
+	obj = reconcile()
+	g.Expect(obj.Status.Stages[2].Phase).To(Equal(promotionsv1alpha1.StagePhaseWaiting))
+	g.Expect(getPromotion("app-staging-to-prod").Spec.Suspend).To(BeTrue())
+
+	// Removing a stage deletes the Promotion of its hop.
+	obj.Spec.Stages = obj.Spec.Stages[:2]
+	g.Expect(c.Update(ctx, obj)).To(Succeed())
+	reconcile()
+	err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "app-staging-to-prod"}, &promotionsv1alpha1.Promotion{})
+	g.Expect(client.IgnoreNotFound(err)).To(Succeed())
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestIsHopOpen(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(promotionsv1alpha1.AddToScheme(scheme)).To(Succeed())
+	r := &PromotionPipelineReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).Build(), Scheme: scheme}
+	pipeline := &promotionsv1alpha1.PromotionPipeline{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+
+	url := newTestRepository(t, map[string]string{"version.yaml": "version: 1.0.0\n"})
+	initial := headTestRepositoryCommit(t, url, "master").Hash.String()
+	pushed := commitTestRepository(t, url, map[string]string{"version.yaml": "version: 1.1.0\n"})
+	later := commitTestRepository(t, url, map[string]string{"README.md": "staging\n"})
+
+	previousSource := &promotionsv1alpha1.Environment{
+		Status: promotionsv1alpha1.EnvironmentStatus{ObservedCommitHash: "a"},
+	}
+	source := &promotionsv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "staging", Namespace: "default"},
+		Spec:       promotionsv1alpha1.EnvironmentSpec{Source: promotionsv1alpha1.Source{URL: url}},
+		Status:     promotionsv1alpha1.EnvironmentStatus{ObservedCommitHash: pushed},
+	}
+	previous := &promotionsv1alpha1.Promotion{
+		Status: promotionsv1alpha1.PromotionStatus{
+			ObservedSourceCommitHash:   "a",
+			LastSyncedSourceCommitHash: "a",
+			LastSyncedTargetCommitHash: pushed,
+		},
+	}
+	isHopOpen := func() bool {
+		open, err := r.isHopOpen(ctx, pipeline, previous, previousSource, source)
+		g.Expect(err).ToNot(HaveOccurred())
+		return open
+	}
+	g.Expect(isHopOpen()).To(BeTrue())
+
+	previous.Spec.Suspend = true
+	g.Expect(isHopOpen()).To(BeFalse())
+	previous.Spec.Suspend = false
+
+	previous.Status.ObservedSourceCommitHash = "c"
+	g.Expect(isHopOpen()).To(BeFalse())
+	previous.Status.ObservedSourceCommitHash = "a"
+
+	// The previous hop hasn't observed the new source commit yet.
+	previousSource.Status.ObservedCommitHash = "c"
+	g.Expect(isHopOpen()).To(BeFalse())
+	previousSource.Status.ObservedCommitHash = "a"
+
+	// The source hasn't observed the pushed commit yet.
+	source.Status.ObservedCommitHash = initial
+	g.Expect(isHopOpen()).To(BeFalse())
+
+	// The source has observed a later commit.
+	source.Status.ObservedCommitHash = later
+	g.Expect(isHopOpen()).To(BeTrue())
+
+	// The pushed commit is not on the branch of the source.
+	previous.Status.LastSyncedTargetCommitHash = strings.Repeat("f", 40)
+	g.Expect(isHopOpen()).To(BeFalse())
+}
+
+func TestPromotionPipelineReconciler_NoStages(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(promotionsv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	pipeline := &promotionsv1alpha1.PromotionPipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pipeline).Build()
+	r := &PromotionPipelineReconciler{Client: c, Scheme: scheme}
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipeline)})
+	g.Expect(err).ToNot(HaveOccurred())
+	obj := &promotionsv1alpha1.PromotionPipeline{}
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(pipeline), obj)).To(Succeed())
+	g.Expect(obj.Status.Stages).To(BeEmpty())
+	g.Expect(meta.IsStatusConditionFalse(obj.Status.Conditions, promotionsv1alpha1.ReadyCondition)).To(BeTrue())
+}
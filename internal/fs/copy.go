@@ -20,11 +20,23 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"syscall"
 )
 
 func CopyDirectory(scrDir, dest string) error {
+	return CopyDirectoryFiltered(scrDir, dest, nil)
+}
+
+// CopyDirectoryFiltered copies the directory like CopyDirectory, but only
+// the files for whose slash-separated path relative to scrDir filter returns
+// true. Directories are only created if files are copied into them.
+func CopyDirectoryFiltered(scrDir, dest string, filter func(path string) bool) error {
+	return copyDirectory(scrDir, dest, "", filter)
+}
+
+func copyDirectory(scrDir, dest, rel string, filter func(path string) bool) error {
 	entries, err := os.ReadDir(scrDir)
 	if err != nil {
 		return err
@@ -32,6 +44,7 @@ func CopyDirectory(scrDir, dest string) error {
 	for _, entry := range entries {
 		sourcePath := filepath.Join(scrDir, entry.Name())
 		destPath := filepath.Join(dest, entry.Name())
+		entryRel := path.Join(rel, entry.Name())
 
 		fileInfo, err := os.Stat(sourcePath)
 		if err != nil {
@@ -45,17 +58,35 @@ func CopyDirectory(scrDir, dest string) error {
 
 		switch fileInfo.Mode() & os.ModeType {
 		case os.ModeDir:
-			if err := CreateIfNotExists(destPath, 0755); err != nil {
-				return err
+			if filter == nil {
+				if err := CreateIfNotExists(destPath, 0755); err != nil {
+					return err
+				}
 			}
-			if err := CopyDirectory(sourcePath, destPath); err != nil {
+			if err := copyDirectory(sourcePath, destPath, entryRel, filter); err != nil {
 				return err
 			}
+			if !Exists(destPath) {
+				// No files were copied into the directory.
+				continue
+			}
 		case os.ModeSymlink:
+			if filter != nil && !filter(entryRel) {
+				continue
+			}
+			if err := CreateIfNotExists(dest, 0755); err != nil {
+				return err
+			}
 			if err := CopySymLink(sourcePath, destPath); err != nil {
 				return err
 			}
 		default:
+			if filter != nil && !filter(entryRel) {
+				continue
+			}
+			if err := CreateIfNotExists(dest, 0755); err != nil {
+				return err
+			}
 			if err := Copy(sourcePath, destPath); err != nil {
 				return err
 			}
@@ -110,6 +141,11 @@ func Exists(filePath string) bool {
 	return true
 }
 
+func IsDir(filePath string) bool {
+	fi, err := os.Stat(filePath)
+	return err == nil && fi.IsDir()
+}
+
 func CreateIfNotExists(dir string, perm os.FileMode) error {
 	if Exists(dir) {
 		return nil
@@ -122,6 +158,68 @@ func CreateIfNotExists(dir string, perm os.FileMode) error {
 	return nil
 }
 
+// Prune deletes the files and directories below destDir which do not exist
+// below srcDir, unless keep returns true for their slash-separated path
+// relative to destDir. A kept directory is kept with all of its contents,
+// other directories are kept if they contain kept files.
+// The .git directory is always kept.
+func Prune(srcDir, destDir string, keep func(path string, isDir bool) bool) error {
+	var dirs []string
+	err := filepath.WalkDir(destDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(destDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if keep(filepath.ToSlash(rel), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+		if _, err := os.Lstat(filepath.Join(srcDir, rel)); os.IsNotExist(err) {
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Remove the directories which became empty, deepest first.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(destDir, dirs[i])
+		if err != nil {
+			return err
+		}
+		if _, err := os.Lstat(filepath.Join(srcDir, rel)); !os.IsNotExist(err) {
+			continue
+		}
+		entries, err := os.ReadDir(dirs[i])
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			if err := os.Remove(dirs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func CopySymLink(source, dest string) error {
 	link, err := os.Readlink(source)
 	if err != nil {
@@ -0,0 +1,265 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bmatcuk/doublestar/v4"
+	. "github.com/onsi/gomega"
+)
+
+// writeTestFiles writes the files, by slash-separated path, below dir.
+// A path ending with a slash creates an empty directory.
+func writeTestFiles(t *testing.T, dir string, files []string) {
+	t.Helper()
+	for _, file := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if file[len(file)-1] == '/' {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// listTestFiles returns the slash-separated paths of the files below dir,
+// and of the empty directories with a trailing slash.
+func listTestFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files := []string{}
+	if !Exists(dir) {
+		return files
+	}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel := filepath.ToSlash(path[len(dir)+1:])
+		if !d.IsDir() {
+			files = append(files, rel)
+			return nil
+		}
+		entries, err := os.ReadDir(path)
+		if err == nil && len(entries) == 0 {
+			files = append(files, rel+"/")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// globFilter returns a filter selecting the paths matching any of the include
+// patterns, or all paths if there are none, and none of the exclude patterns.
+func globFilter(include, exclude []string) func(path string) bool {
+	if include == nil && exclude == nil {
+		return nil
+	}
+	return func(path string) bool {
+		included := len(include) == 0
+		for _, pattern := range include {
+			if ok, _ := doublestar.Match(pattern, path); ok {
+				included = true
+			}
+		}
+		for _, pattern := range exclude {
+			if ok, _ := doublestar.Match(pattern, path); ok {
+				return false
+			}
+		}
+		return included
+	}
+}
+
+func TestCopyDirectoryFiltered(t *testing.T) {
+	source := []string{
+		"kustomization.yaml",
+		"apps/app.yaml",
+		"apps/secret.enc.yaml",
+		"apps/nested/deep/deployment.yaml",
+		"infra/config.yaml",
+		"empty/",
+	}
+
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		existing []string
+		want     []string
+	}{
+		{
+			name: "no filter",
+			want: []string{
+				"apps/app.yaml",
+				"apps/nested/deep/deployment.yaml",
+				"apps/secret.enc.yaml",
+				"empty/",
+				"infra/config.yaml",
+				"kustomization.yaml",
+			},
+		},
+		{
+			name:    "include nested directory",
+			include: []string{"apps/**"},
+			want: []string{
+				"apps/app.yaml",
+				"apps/nested/deep/deployment.yaml",
+				"apps/secret.enc.yaml",
+			},
+		},
+		{
+			name:    "exclude glob",
+			exclude: []string{"**/*.enc.yaml", "kustomization.yaml"},
+			want: []string{
+				"apps/app.yaml",
+				"apps/nested/deep/deployment.yaml",
+				"infra/config.yaml",
+			},
+		},
+		{
+			name:    "include and exclude",
+			include: []string{"**/*.yaml"},
+			exclude: []string{"apps/nested/**", "infra/*"},
+			want: []string{
+				"apps/app.yaml",
+				"apps/secret.enc.yaml",
+				"kustomization.yaml",
+			},
+		},
+		{
+			name:     "files outside the filter are kept",
+			include:  []string{"apps/*.yaml"},
+			existing: []string{"kustomization.yaml", "apps/app.yaml", "apps/local.yaml", "other/file.yaml"},
+			want: []string{
+				"apps/app.yaml",
+				"apps/local.yaml",
+				"apps/secret.enc.yaml",
+				"kustomization.yaml",
+				"other/file.yaml",
+			},
+		},
+		{
+			name:    "nothing selected",
+			include: []string{"missing/**"},
+			want:    []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			src := t.TempDir()
+			writeTestFiles(t, src, source)
+			dest := filepath.Join(t.TempDir(), "dest")
+			writeTestFiles(t, dest, tt.existing)
+
+			g.Expect(CopyDirectoryFiltered(src, dest, globFilter(tt.include, tt.exclude))).To(Succeed())
+			g.Expect(listTestFiles(t, dest)).To(Equal(tt.want))
+
+			// Copied files have the content of the source.
+			for _, file := range tt.want {
+				if file[len(file)-1] == '/' {
+					continue
+				}
+				content, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(file)))
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(string(content)).To(Equal(file))
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  []string
+		dest    []string
+		include []string
+		exclude []string
+		protect []string
+		want    []string
+	}{
+		{
+			name:   "delete files missing in the source",
+			source: []string{"apps/app.yaml", "kept/"},
+			dest:   []string{"apps/app.yaml", "apps/old.yaml", "old/nested/file.yaml", "kept/old.yaml"},
+			want:   []string{"apps/app.yaml", "kept/"},
+		},
+		{
+			name:   "keep .git",
+			source: []string{"app.yaml"},
+			dest:   []string{"app.yaml", ".git/HEAD", ".git/objects/ab/cdef"},
+			want:   []string{".git/HEAD", ".git/objects/ab/cdef", "app.yaml"},
+		},
+		{
+			name:    "keep files outside the filter",
+			source:  []string{"apps/app.yaml"},
+			dest:    []string{"apps/app.yaml", "apps/old.yaml", "apps/notes.md", "README.md", "other/old.yaml"},
+			include: []string{"apps/**"},
+			exclude: []string{"**/*.md"},
+			want:    []string{"README.md", "apps/app.yaml", "apps/notes.md", "other/old.yaml"},
+		},
+		{
+			name:    "keep protected files",
+			source:  []string{"apps/app.yaml"},
+			dest:    []string{"apps/app.yaml", "apps/local.yaml", "apps/old.yaml"},
+			protect: []string{"**/local.yaml"},
+			want:    []string{"apps/app.yaml", "apps/local.yaml"},
+		},
+		{
+			name:    "keep protected directories with their contents",
+			source:  []string{"apps/app.yaml"},
+			dest:    []string{"apps/app.yaml", "local/a.yaml", "local/nested/b.yaml", "old/c.yaml"},
+			protect: []string{"local"},
+			want:    []string{"apps/app.yaml", "local/a.yaml", "local/nested/b.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			src := t.TempDir()
+			writeTestFiles(t, src, tt.source)
+			dest := t.TempDir()
+			writeTestFiles(t, dest, tt.dest)
+
+			filter := globFilter(tt.include, tt.exclude)
+			protected := globFilter(tt.protect, nil)
+			err := Prune(src, dest, func(path string, isDir bool) bool {
+				if !isDir && filter != nil && !filter(path) {
+					return true
+				}
+				return protected != nil && protected(path)
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(listTestFiles(t, dest)).To(Equal(tt.want))
+		})
+	}
+}
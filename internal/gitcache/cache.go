@@ -0,0 +1,364 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gitcache keeps bare mirrors of the branches of remote repositories
+// on disk, so that reconciles only fetch new commits from the git server and
+// clone their worktrees from the local mirror.
+package gitcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+)
+
+// Scheme is the URL scheme worktrees are cloned from the mirrors with.
+// It is served in-process, so no git binary is needed. The protocol is only
+// installed by New, and serves the mirrors of the most recently created Cache.
+const Scheme = "gitcache"
+
+// evictedPrefix prefixes the names evicted mirrors are renamed to,
+// before they are deleted.
+const evictedPrefix = ".evicted-"
+
+// Cache is a cache of bare mirrors of remote repositories, keyed by URL and
+// branch. It is safe for concurrent use.
+type Cache struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+
+	mu      sync.Mutex
+	mirrors map[string]*mirror
+}
+
+// mirror is a bare mirror of a branch of a remote repository.
+type mirror struct {
+	// mu guards the mirror on disk.
+	mu   sync.Mutex
+	path string
+
+	// lastUsed, users and size are guarded by Cache.mu.
+	lastUsed time.Time
+	// users is the number of checkouts in progress,
+	// a mirror is never evicted while it is in use.
+	users int
+	// size is the size of the mirror on disk in bytes,
+	// updated whenever new objects are fetched into it.
+	size int64
+}
+
+// New returns a Cache storing the mirrors in dir.
+// Mirrors which were not used for maxAge are evicted, and the least recently
+// used mirrors are evicted while the cache is larger than maxSize bytes.
+// Zero disables the respective limit.
+// Mirrors left in dir by a previous process are reused.
+func New(dir string, maxSize int64, maxAge time.Duration) (*Cache, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// Only the mirrors in dir can be cloned with the scheme.
+	client.InstallProtocol(Scheme, server.NewClient(mirrorLoader{server.NewFilesystemLoader(osfs.New(dir))}))
+
+	c := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+		mirrors: map[string]*mirror{},
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		// Finish deleting the mirrors evicted by a previous process.
+		if strings.HasPrefix(entry.Name(), evictedPrefix) {
+			if err := os.RemoveAll(path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		c.mirrors[entry.Name()] = &mirror{
+			path:     path,
+			lastUsed: info.ModTime(),
+			size:     dirSize(path),
+		}
+	}
+	return c, nil
+}
+
+// mirrorLoader loads the mirrors in the directory of a Cache by their name.
+// Other paths are rejected, as the loader would resolve them relative to the
+// directory, possibly outside of it.
+type mirrorLoader struct {
+	server.Loader
+}
+
+func (l mirrorLoader) Load(ep *transport.Endpoint) (storer.Storer, error) {
+	name := strings.TrimPrefix(ep.Path, "/")
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, transport.ErrRepositoryNotFound
+	}
+	return l.Loader.Load(ep)
+}
+
+// Checkout fetches the branch of the repository at url into its mirror,
+// and clones a worktree of the branch from the mirror into dir.
+// The remote "origin" of the returned repository points to url.
+func (c *Cache) Checkout(ctx context.Context, url string, branch string, auth transport.AuthMethod, dir string) (*gogit.Repository, error) {
+	return c.CheckoutReference(ctx, url, plumbing.NewBranchReferenceName(branch), auth, dir)
+}
+
+// CheckoutReference is like Checkout, but checks out any branch or tag.
+func (c *Cache) CheckoutReference(ctx context.Context, url string, ref plumbing.ReferenceName, auth transport.AuthMethod, dir string) (*gogit.Repository, error) {
+	// Branches are keyed by their short name, like before tags were supported.
+	name := ref.String()
+	if ref.IsBranch() {
+		name = ref.Short()
+	}
+	m := c.acquire(key(url, name))
+	defer c.release(m)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	updated, err := m.update(ctx, url, ref, auth)
+	if err != nil {
+		return nil, err
+	}
+	if updated {
+		// Measured while only this mirror is locked,
+		// so that other checkouts don't wait for it.
+		c.resize(m, dirSize(m.path))
+	}
+
+	repo, err := gogit.PlainCloneContext(ctx, dir, false, &gogit.CloneOptions{
+		URL:           Scheme + ":///" + filepath.Base(m.path),
+		ReferenceName: ref,
+		SingleBranch:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repo.DeleteRemote("origin"); err != nil {
+		return nil, err
+	}
+	fetch := config.RefSpec("+" + ref.String() + ":" + ref.String())
+	if ref.IsBranch() {
+		fetch = config.RefSpec("+" + ref.String() + ":" + plumbing.NewRemoteReferenceName("origin", ref.Short()).String())
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{
+		Name:  "origin",
+		URLs:  []string{url},
+		Fetch: []config.RefSpec{fetch},
+	}); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// update fetches the reference into the mirror, or clones the mirror if it does
+// not exist or can't be opened. It reports whether the mirror changed.
+// m.mu must be held.
+func (m *mirror) update(ctx context.Context, url string, ref plumbing.ReferenceName, auth transport.AuthMethod) (bool, error) {
+	if repo, err := gogit.PlainOpen(m.path); err == nil {
+		err = repo.FetchContext(ctx, &gogit.FetchOptions{
+			RemoteURL: url,
+			RefSpecs:  []config.RefSpec{config.RefSpec("+" + ref.String() + ":" + ref.String())},
+			Auth:      auth,
+			Force:     true,
+		})
+		if errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	if err := os.RemoveAll(m.path); err != nil {
+		return false, err
+	}
+	_, err := gogit.PlainCloneContext(ctx, m.path, true, &gogit.CloneOptions{
+		URL:           url,
+		ReferenceName: ref,
+		SingleBranch:  true,
+		Auth:          auth,
+	})
+	if err != nil {
+		os.RemoveAll(m.path)
+	}
+	return err == nil, err
+}
+
+// acquire returns the mirror for the key, and marks it as in use.
+func (c *Cache) acquire(key string) *mirror {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, ok := c.mirrors[key]
+	if !ok {
+		m = &mirror{path: filepath.Join(c.dir, key)}
+		c.mirrors[key] = m
+	}
+	m.users++
+	m.lastUsed = time.Now()
+	return m
+}
+
+// resize records the size of the mirror on disk.
+func (c *Cache) resize(m *mirror, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m.size = size
+}
+
+// release marks the mirror as no longer in use by a checkout,
+// and evicts the mirrors exceeding the limits of the cache.
+func (c *Cache) release(m *mirror) {
+	c.mu.Lock()
+	m.users--
+	m.lastUsed = time.Now()
+	evicted := c.evict()
+	c.mu.Unlock()
+
+	// The evicted mirrors were moved out of the way,
+	// so they are deleted without blocking other checkouts.
+	for _, path := range evicted {
+		os.RemoveAll(path)
+	}
+}
+
+// evict removes the mirrors which are not in use and exceed the limits of
+// the cache, and returns the paths they were moved to for deletion.
+// c.mu must be held, so that evicted mirrors can't be acquired.
+func (c *Cache) evict() []string {
+	var idle []string
+	for key, m := range c.mirrors {
+		if m.users == 0 {
+			idle = append(idle, key)
+		}
+	}
+	// Least recently used first.
+	sort.Slice(idle, func(i, j int) bool {
+		return c.mirrors[idle[i]].lastUsed.Before(c.mirrors[idle[j]].lastUsed)
+	})
+
+	var evicted []string
+	remove := func(key string) bool {
+		// A failed removal is retried on the next eviction.
+		path := filepath.Join(c.dir, evictedPrefix+key+"-"+strconv.FormatInt(time.Now().UnixNano(), 10))
+		if err := os.Rename(c.mirrors[key].path, path); err != nil && !os.IsNotExist(err) {
+			return false
+		}
+		evicted = append(evicted, path)
+		delete(c.mirrors, key)
+		return true
+	}
+
+	if c.maxAge > 0 {
+		var kept []string
+		for _, key := range idle {
+			if time.Since(c.mirrors[key].lastUsed) <= c.maxAge || !remove(key) {
+				kept = append(kept, key)
+			}
+		}
+		idle = kept
+	}
+
+	if c.maxSize > 0 {
+		size := c.size()
+		for _, key := range idle {
+			if size <= c.maxSize {
+				break
+			}
+			if m := c.mirrors[key]; remove(key) {
+				size -= m.size
+			}
+		}
+	}
+	return evicted
+}
+
+// Size returns the size of the mirrors on disk in bytes.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size()
+}
+
+// size returns the recorded size of the mirrors. c.mu must be held.
+func (c *Cache) size() int64 {
+	var size int64
+	for _, m := range c.mirrors {
+		size += m.size
+	}
+	return size
+}
+
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// key returns the name of the directory of the mirror of the reference of the
+// repository at url.
+func key(url string, ref string) string {
+	sum := sha256.Sum256([]byte(url + "\n" + ref))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitcache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	. "github.com/onsi/gomega"
+)
+
+func init() {
+	// Serve file:// URLs in-process, so the tests don't depend on a git binary.
+	client.InstallProtocol("file", server.DefaultServer)
+}
+
+// newTestRepository creates a repository with a commit on the "master" branch,
+// and returns its URL and a function which commits the given file to it.
+func newTestRepository(t *testing.T) (string, func(path, content string) string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := gogit.Init(filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault()), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(path, content string) string {
+		if err := billyutil.WriteFile(wt.Filesystem, path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(path); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := wt.Commit("update "+path, &gogit.CommitOptions{
+			Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash.String()
+	}
+	commit("README.md", "# test\n")
+
+	return "file://" + dir, commit
+}
+
+func TestCache_Checkout(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	url, commit := newTestRepository(t)
+	c, err := New(t.TempDir(), 0, 0)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	checkout := func() *gogit.Repository {
+		repo, err := c.Checkout(ctx, url, "master", nil, t.TempDir())
+		g.Expect(err).ToNot(HaveOccurred())
+		return repo
+	}
+	head := func(repo *gogit.Repository) string {
+		ref, err := repo.Head()
+		g.Expect(err).ToNot(HaveOccurred())
+		return ref.Hash().String()
+	}
+
+	repo := checkout()
+	remote, err := repo.Remote("origin")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remote.Config().URLs).To(ConsistOf(url))
+	// The size of the mirror is recorded when it is fetched.
+	g.Expect(c.Size()).To(BeNumerically(">", 0))
+	g.Expect(c.Size()).To(Equal(dirSize(c.dir)))
+
+	// New commits are fetched into the mirror.
+	hash := commit("app.yaml", "version: 1.1.0\n")
+	repo = checkout()
+	g.Expect(head(repo)).To(Equal(hash))
+	wt, err := repo.Worktree()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(billyutil.ReadFile(wt.Filesystem, "app.yaml")).To(BeEquivalentTo("version: 1.1.0\n"))
+
+	// Concurrent checkouts share the mirror.
+	hash = commit("app.yaml", "version: 1.2.0\n")
+	var wg sync.WaitGroup
+	heads := make([]string, 8)
+	for i := range heads {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			repo, err := c.Checkout(ctx, url, "master", nil, filepath.Join(t.TempDir(), "worktree"))
+			if err == nil {
+				if ref, err := repo.Head(); err == nil {
+					heads[i] = ref.Hash().String()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, h := range heads {
+		g.Expect(h).To(Equal(hash))
+	}
+
+	// Mirrors are reused by a new cache in the same directory.
+	entries, err := os.ReadDir(c.dir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entries).To(HaveLen(1))
+	reopened, err := New(c.dir, 0, 0)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(reopened.mirrors).To(HaveLen(1))
+}
+
+func TestCache_Scheme(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	url, _ := newTestRepository(t)
+	c, err := New(t.TempDir(), 0, 0)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.Checkout(ctx, url, "master", nil, t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// The mirrors in the cache can be cloned with the scheme.
+	_, err = gogit.PlainCloneContext(ctx, t.TempDir(), false, &gogit.CloneOptions{URL: Scheme + ":///" + key(url, "master")})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Repositories outside of the cache can't.
+	for _, path := range []string{strings.TrimPrefix(url, "file://"), "../" + filepath.Base(strings.TrimPrefix(url, "file://"))} {
+		_, err = gogit.PlainCloneContext(ctx, t.TempDir(), false, &gogit.CloneOptions{URL: Scheme + ":///" + path})
+		g.Expect(err).To(HaveOccurred(), path)
+	}
+}
+
+func TestCache_Evict(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	first, _ := newTestRepository(t)
+	second, _ := newTestRepository(t)
+
+	// Mirrors which were not used for maxAge are evicted.
+	c, err := New(t.TempDir(), 0, time.Hour)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.Checkout(ctx, first, "master", nil, t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	c.mirrors[key(first, "master")].lastUsed = time.Now().Add(-2 * time.Hour)
+	_, err = c.Checkout(ctx, second, "master", nil, t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c.mirrors).To(HaveKey(key(second, "master")))
+	g.Expect(c.mirrors).ToNot(HaveKey(key(first, "master")))
+	g.Expect(filepath.Join(c.dir, key(first, "master"))).ToNot(BeADirectory())
+
+	// The least recently used mirrors are evicted while the cache is too large.
+	c, err = New(t.TempDir(), 1, 0)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.Checkout(ctx, first, "master", nil, t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(c.mirrors).To(BeEmpty())
+	g.Expect(c.Size()).To(BeZero())
+	entries, err := os.ReadDir(c.dir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entries).To(BeEmpty())
+}
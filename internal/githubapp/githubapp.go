@@ -0,0 +1,228 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package githubapp mints and caches installation access tokens of GitHub Apps,
+// which are used instead of long-lived personal access tokens.
+package githubapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBaseURL is the URL of the API of github.com.
+	DefaultBaseURL = "https://api.github.com"
+
+	// refreshBefore is how long before their expiry tokens are refreshed,
+	// so that a token doesn't expire while it is in use.
+	refreshBefore = 5 * time.Minute
+	// jwtLifetime is the lifetime of the JWTs authenticating as the app.
+	// GitHub accepts at most 10 minutes.
+	jwtLifetime = 9 * time.Minute
+)
+
+// App is the installation of a GitHub App tokens are minted for.
+type App struct {
+	// BaseURL is the URL of the GitHub API, defaults to DefaultBaseURL.
+	BaseURL        string
+	AppID          int64
+	InstallationID int64
+	PrivateKey     *rsa.PrivateKey
+}
+
+// ParsePrivateKey parses the PEM encoded private key of a GitHub App.
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded GitHub App private key found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub App private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GitHub App private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// JWT returns a JSON Web Token authenticating as the app, issued at now.
+func (a App) JWT(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		// Allow for clock drift between the operator and GitHub.
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+		"iss": a.AppID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.PrivateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Token is an installation access token.
+type Token struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Cache mints installation access tokens, and caches them until shortly
+// before they expire. It is safe for concurrent use.
+type Cache struct {
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu     sync.Mutex
+	tokens map[string]Token
+	// minting holds the tokens being minted, by installation.
+	minting map[string]*mintCall
+}
+
+// mintCall is a token being minted, which concurrent callers wait for.
+type mintCall struct {
+	done  chan struct{}
+	token Token
+	err   error
+}
+
+// DefaultCache is the Cache shared by the controllers.
+var DefaultCache = NewCache(nil)
+
+// NewCache returns a Cache minting tokens with httpClient.
+// If httpClient is nil, http.DefaultClient is used.
+func NewCache(httpClient *http.Client) *Cache {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Cache{
+		httpClient: httpClient,
+		now:        time.Now,
+		tokens:     map[string]Token{},
+		minting:    map[string]*mintCall{},
+	}
+}
+
+// Token returns an installation access token of the app, which is valid for
+// at least a few more minutes. A new token is minted if there is no such
+// token in the cache.
+func (c *Cache) Token(ctx context.Context, app App) (string, error) {
+	if app.BaseURL == "" {
+		app.BaseURL = DefaultBaseURL
+	}
+	key := fmt.Sprintf("%s/%d/%d", strings.TrimSuffix(app.BaseURL, "/"), app.AppID, app.InstallationID)
+
+	c.mu.Lock()
+	now := c.now()
+	if token, ok := c.tokens[key]; ok && now.Add(refreshBefore).Before(token.ExpiresAt) {
+		c.mu.Unlock()
+		return token.Token, nil
+	}
+	// Concurrent reconciles of the same installation share one new token,
+	// other installations don't wait for it.
+	call, ok := c.minting[key]
+	if !ok {
+		call = &mintCall{done: make(chan struct{})}
+		c.minting[key] = call
+	}
+	c.mu.Unlock()
+
+	if ok {
+		select {
+		case <-call.done:
+			return call.token.Token, call.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	call.token, call.err = c.mint(ctx, app, now)
+
+	c.mu.Lock()
+	delete(c.minting, key)
+	if call.err == nil {
+		c.tokens[key] = call.token
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.token.Token, call.err
+}
+
+// mint creates a new installation access token of the app.
+func (c *Cache) mint(ctx context.Context, app App, now time.Time) (Token, error) {
+	jwt, err := app.JWT(now)
+	if err != nil {
+		return Token{}, err
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", strings.TrimSuffix(app.BaseURL, "/"), app.InstallationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Token{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return Token{}, fmt.Errorf("failed to create installation access token for GitHub App %d: %s: %s", app.AppID, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var token Token
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return Token{}, err
+	}
+	if token.Token == "" {
+		return Token{}, fmt.Errorf("no installation access token returned for GitHub App %d", app.AppID)
+	}
+	return token, nil
+}
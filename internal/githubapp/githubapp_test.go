@@ -0,0 +1,198 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package githubapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+// newTestTokenServer stubs the endpoint creating installation access tokens.
+// It verifies the JWT against the public key, and returns a new token valid
+// for an hour on every request. The number of requests is counted in minted.
+func newTestTokenServer(t *testing.T, publicKey *rsa.PublicKey, minted *atomic.Int32) string {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" {
+			http.NotFound(w, r)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+		if len(parts) != 3 {
+			http.Error(w, "invalid JWT", http.StatusUnauthorized)
+			return
+		}
+		sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], sig); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil || !strings.Contains(string(claims), `"iss":7`) {
+			http.Error(w, "invalid issuer", http.StatusUnauthorized)
+			return
+		}
+
+		n := minted.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(Token{
+			Token:     fmt.Sprintf("ghs_%d", n),
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestCache_Token(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	var minted atomic.Int32
+	app := App{
+		BaseURL:        newTestTokenServer(t, &key.PublicKey, &minted),
+		AppID:          7,
+		InstallationID: 42,
+		PrivateKey:     key,
+	}
+
+	c := NewCache(nil)
+	token, err := c.Token(ctx, app)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token).To(Equal("ghs_1"))
+
+	// The token is cached.
+	token, err = c.Token(ctx, app)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token).To(Equal("ghs_1"))
+	g.Expect(minted.Load()).To(Equal(int32(1)))
+
+	// The token is refreshed shortly before it expires.
+	c.now = func() time.Time { return time.Now().Add(time.Hour - refreshBefore) }
+	token, err = c.Token(ctx, app)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token).To(Equal("ghs_2"))
+	g.Expect(minted.Load()).To(Equal(int32(2)))
+
+	// Tokens of other installations are not shared.
+	app.InstallationID = 43
+	_, err = c.Token(ctx, app)
+	g.Expect(err).To(HaveOccurred())
+
+	// A JWT signed with another key is rejected.
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	app.InstallationID = 42
+	app.PrivateKey = other
+	_, err = NewCache(nil).Token(ctx, app)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("401"))
+}
+
+func TestCache_TokenConcurrent(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Tokens of installation 1 are only returned once release is closed.
+	release := make(chan struct{})
+	var minted atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/app/installations/1/access_tokens" {
+			minted.Add(1)
+			<-release
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(Token{
+			Token:     "ghs_" + strings.Split(r.URL.Path, "/")[3],
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+	}))
+	defer srv.Close()
+	app := App{BaseURL: srv.URL, AppID: 7, InstallationID: 1, PrivateKey: key}
+
+	c := NewCache(nil)
+	tokens := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			token, _ := c.Token(ctx, app)
+			tokens <- token
+		}()
+	}
+	g.Eventually(minted.Load).Should(Equal(int32(1)))
+
+	// Other installations don't wait for the token being minted.
+	other := app
+	other.InstallationID = 2
+	token, err := c.Token(ctx, other)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token).To(Equal("ghs_2"))
+
+	// Concurrent callers of the same installation share one token.
+	close(release)
+	for i := 0; i < 3; i++ {
+		g.Eventually(tokens).Should(Receive(Equal("ghs_1")))
+	}
+	g.Expect(minted.Load()).To(Equal(int32(1)))
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	g := NewWithT(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// GitHub hands out PKCS#1 keys, PKCS#8 keys are accepted too.
+	parsed, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(parsed.Equal(key)).To(BeTrue())
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	g.Expect(err).ToNot(HaveOccurred())
+	parsed, err = ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(parsed.Equal(key)).To(BeTrue())
+
+	_, err = ParsePrivateKey([]byte("not a key"))
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kustomize edits kustomization files.
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/thomasstxyz/gitops-promotions-operator/internal/yamlpath"
+)
+
+// KustomizationFileNames are the file names kustomize recognizes
+// as kustomization files, in the order it looks for them.
+var KustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// Image is an entry of the images field of a kustomization.
+type Image struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName,omitempty"`
+	NewTag  string `yaml:"newTag,omitempty"`
+	Digest  string `yaml:"digest,omitempty"`
+}
+
+// FindKustomization returns the path of the kustomization file, given the
+// path of either the file itself or the directory containing it.
+func FindKustomization(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return path, nil
+	}
+	for _, name := range KustomizationFileNames {
+		file := filepath.Join(path, name)
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("no kustomization file found in %s", path)
+}
+
+// Images returns the images of the kustomization.
+func Images(kustomization []byte) ([]Image, error) {
+	node, err := yamlpath.Get(kustomization, "images")
+	if errors.Is(err, yamlpath.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var images []Image
+	if err := node.Decode(&images); err != nil {
+		return nil, fmt.Errorf("invalid images: %w", err)
+	}
+	return images, nil
+}
+
+// MergeImages merges the images with the given names from the source
+// kustomization into the target kustomization, and returns the result.
+// All images of the source are merged if no names are given.
+// Images which are only in the target are left untouched.
+func MergeImages(source []byte, target []byte, names []string) ([]byte, error) {
+	sourceImages, err := Images(source)
+	if err != nil {
+		return nil, err
+	}
+	targetImages, err := Images(target)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := map[string]bool{}
+	for _, name := range names {
+		selected[name] = false
+	}
+
+	var missing []*yaml.Node
+	for i, image := range sourceImages {
+		if _, ok := selected[image.Name]; !ok && len(names) > 0 {
+			continue
+		}
+		selected[image.Name] = true
+
+		sourcePath := fmt.Sprintf("images[%d]", i)
+		j := indexOf(targetImages, image.Name)
+		switch {
+		case j >= 0:
+			if target, err = yamlpath.Copy(source, sourcePath, target, fmt.Sprintf("images[%d]", j)); err != nil {
+				return nil, err
+			}
+		case targetImages != nil:
+			// Append the image to the images of the target.
+			if target, err = yamlpath.Copy(source, sourcePath, target, fmt.Sprintf("images[%d]", len(targetImages))); err != nil {
+				return nil, err
+			}
+			targetImages = append(targetImages, image)
+		default:
+			node, err := yamlpath.Get(source, sourcePath)
+			if err != nil {
+				return nil, err
+			}
+			missing = append(missing, node)
+		}
+	}
+
+	for _, name := range names {
+		if !selected[name] {
+			return nil, fmt.Errorf("image %s not found in source kustomization", name)
+		}
+	}
+
+	// The target has no images yet.
+	if len(missing) > 0 {
+		return yamlpath.Set(target, "images", &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: missing})
+	}
+	return target, nil
+}
+
+func indexOf(images []Image, name string) int {
+	for i, image := range images {
+		if image.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const sourceKustomization = `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- ../../base
+images:
+- name: ghcr.io/example/app
+  newTag: 1.1.0 # promoted
+- name: ghcr.io/example/worker
+  digest: sha256:2222
+- name: ghcr.io/example/debug
+  newTag: latest
+`
+
+const targetKustomization = `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- ../../base
+# pinned versions
+images:
+- name: ghcr.io/example/worker
+  newTag: 1.0.0
+- name: ghcr.io/example/app
+  newTag: 1.0.0
+- name: ghcr.io/example/proxy # prod only
+  newTag: 2.0.0
+replicas:
+- name: app
+  count: 3
+`
+
+func TestMergeImages(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		names   []string
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "selected images",
+			target: targetKustomization,
+			names:  []string{"ghcr.io/example/app", "ghcr.io/example/worker"},
+			want: `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- ../../base
+# pinned versions
+images:
+- name: ghcr.io/example/worker
+  digest: sha256:2222
+- name: ghcr.io/example/app
+  newTag: 1.1.0 # promoted
+- name: ghcr.io/example/proxy # prod only
+  newTag: 2.0.0
+replicas:
+- name: app
+  count: 3
+`,
+		},
+		{
+			name:   "all images",
+			target: targetKustomization,
+			want: `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- ../../base
+# pinned versions
+images:
+- name: ghcr.io/example/worker
+  digest: sha256:2222
+- name: ghcr.io/example/app
+  newTag: 1.1.0 # promoted
+- name: ghcr.io/example/proxy # prod only
+  newTag: 2.0.0
+- name: ghcr.io/example/debug
+  newTag: latest
+replicas:
+- name: app
+  count: 3
+`,
+		},
+		{
+			name:   "target without images",
+			target: "resources:\n- ../../base\n",
+			names:  []string{"ghcr.io/example/app"},
+			want:   "resources:\n- ../../base\nimages:\n- name: ghcr.io/example/app\n  newTag: 1.1.0 # promoted\n",
+		},
+		{
+			name:    "unknown image",
+			target:  targetKustomization,
+			names:   []string{"ghcr.io/example/unknown"},
+			wantErr: "image ghcr.io/example/unknown not found in source kustomization",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := MergeImages([]byte(sourceKustomization), []byte(tt.target), tt.names)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(string(got)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestFindKustomization(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	_, err := FindKustomization(dir)
+	g.Expect(err).To(HaveOccurred())
+
+	file := filepath.Join(dir, "kustomization.yml")
+	g.Expect(os.WriteFile(file, nil, 0644)).To(Succeed())
+	g.Expect(FindKustomization(dir)).To(Equal(file))
+	g.Expect(FindKustomization(file)).To(Equal(file))
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// giteaPageSize is the number of pull requests requested per page.
+const giteaPageSize = 50
+
+// Gitea implements Provider for Gitea and Forgejo, which share the same API.
+type Gitea struct {
+	apiURL     string
+	token      string
+	owner      string
+	repo       string
+	httpClient *http.Client
+}
+
+// NewGitea returns a Provider for the repository at repoURL.
+// baseURL is the base URL of the Gitea instance, e.g. "https://gitea.example.com".
+// If empty, it is derived from the scheme and host of repoURL.
+func NewGitea(baseURL, repoURL, token string, httpClient *http.Client) (*Gitea, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(strings.Trim(strings.TrimSuffix(u.Path, ".git"), "/"), "/")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("unable to parse owner and repository from URL %q", repoURL)
+	}
+
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("%s://%s", u.Scheme, u.Host)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Gitea{
+		apiURL:     strings.TrimSuffix(baseURL, "/") + "/api/v1",
+		token:      token,
+		owner:      parts[len(parts)-2],
+		repo:       parts[len(parts)-1],
+		httpClient: httpClient,
+	}, nil
+}
+
+type giteaPullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	HTMLURL string `json:"html_url"`
+	State   string `json:"state"`
+	Merged  bool   `json:"merged"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+}
+
+func (pr giteaPullRequest) toPullRequest() PullRequest {
+	return PullRequest{
+		Number:       pr.Number,
+		Title:        pr.Title,
+		WebURL:       pr.HTMLURL,
+		SourceBranch: pr.Head.Ref,
+		Open:         pr.State == "open" && !pr.Merged,
+		Merged:       pr.Merged,
+	}
+}
+
+func (g *Gitea) ListPullRequests(ctx context.Context) ([]PullRequest, error) {
+	var open []PullRequest
+	for page := 1; ; page++ {
+		var prs []giteaPullRequest
+		path := fmt.Sprintf("%s/pulls?state=open&limit=%d&page=%d", g.repoPath(), giteaPageSize, page)
+		if err := g.do(ctx, http.MethodGet, path, nil, &prs); err != nil {
+			return nil, err
+		}
+		for _, pr := range prs {
+			open = append(open, pr.toPullRequest())
+		}
+		if len(prs) < giteaPageSize {
+			return open, nil
+		}
+	}
+}
+
+func (g *Gitea) GetPullRequest(ctx context.Context, number int) (PullRequest, error) {
+	var pr giteaPullRequest
+	path := fmt.Sprintf("%s/pulls/%d", g.repoPath(), number)
+	if err := g.do(ctx, http.MethodGet, path, nil, &pr); err != nil {
+		return PullRequest{}, err
+	}
+	return pr.toPullRequest(), nil
+}
+
+func (g *Gitea) CreatePullRequest(ctx context.Context, title, branch, baseBranch, description string) (PullRequest, error) {
+	body := map[string]string{
+		"title": title,
+		"head":  branch,
+		"base":  baseBranch,
+		"body":  description,
+	}
+	var pr giteaPullRequest
+	path := g.repoPath() + "/pulls"
+	if err := g.do(ctx, http.MethodPost, path, body, &pr); err != nil {
+		return PullRequest{}, err
+	}
+	return pr.toPullRequest(), nil
+}
+
+func (g *Gitea) EditPullRequest(ctx context.Context, number int, title, description string) (PullRequest, error) {
+	body := map[string]string{
+		"title": title,
+		"body":  description,
+	}
+	var pr giteaPullRequest
+	path := fmt.Sprintf("%s/pulls/%d", g.repoPath(), number)
+	if err := g.do(ctx, http.MethodPatch, path, body, &pr); err != nil {
+		return PullRequest{}, err
+	}
+	return pr.toPullRequest(), nil
+}
+
+func (g *Gitea) ClosePullRequest(ctx context.Context, number int, comment string) error {
+	if comment != "" {
+		path := fmt.Sprintf("%s/issues/%d/comments", g.repoPath(), number)
+		if err := g.do(ctx, http.MethodPost, path, map[string]string{"body": comment}, nil); err != nil {
+			return err
+		}
+	}
+	path := fmt.Sprintf("%s/pulls/%d", g.repoPath(), number)
+	return g.do(ctx, http.MethodPatch, path, map[string]string{"state": "closed"}, nil)
+}
+
+// repoPath returns the API path of the repository, with the owner and repository escaped.
+func (g *Gitea) repoPath() string {
+	return "/repos/" + url.PathEscape(g.owner) + "/" + url.PathEscape(g.repo)
+}
+
+// do sends a request to the Gitea API and decodes the JSON response into out.
+func (g *Gitea) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var reqBody io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.apiURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if g.token != "" {
+		req.Header.Set("Authorization", "token "+g.token)
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("gitea API %s %s failed with status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
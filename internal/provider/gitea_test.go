@@ -0,0 +1,234 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// fakeGitea is a minimal stand-in for the pull request API of a Gitea server.
+type fakeGitea struct {
+	mu       sync.Mutex
+	token    string
+	prs      []map[string]interface{}
+	comments []string
+}
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "token "+f.token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/api/v1/repos/org/repo/issues/") && r.Method == http.MethodPost {
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		f.comments = append(f.comments, in["body"])
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(in)
+		return
+	}
+
+	const prefix = "/api/v1/repos/org/repo/pulls"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	rest := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+	switch {
+	case rest == "" && r.Method == http.MethodGet:
+		var open []map[string]interface{}
+		for _, pr := range f.prs {
+			if pr["state"] == r.URL.Query().Get("state") {
+				open = append(open, pr)
+			}
+		}
+		json.NewEncoder(w).Encode(open)
+	case rest == "" && r.Method == http.MethodPost:
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		n := len(f.prs) + 1
+		pr := map[string]interface{}{
+			"number":   n,
+			"title":    in["title"],
+			"body":     in["body"],
+			"html_url": fmt.Sprintf("http://%s/org/repo/pulls/%d", r.Host, n),
+			"state":    "open",
+			"merged":   false,
+			"head":     map[string]string{"ref": in["head"]},
+		}
+		f.prs = append(f.prs, pr)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(pr)
+	default:
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 1 || n > len(f.prs) {
+			http.NotFound(w, r)
+			return
+		}
+		pr := f.prs[n-1]
+		if r.Method == http.MethodPatch {
+			var in map[string]string
+			json.NewDecoder(r.Body).Decode(&in)
+			for _, key := range []string{"title", "body", "state"} {
+				if v, ok := in[key]; ok {
+					pr[key] = v
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(pr)
+	}
+}
+
+func TestGitea(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	fake := &fakeGitea{token: "secret"}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p, err := NewGitea("", server.URL+"/org/repo.git", "secret", server.Client())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	prs, err := p.ListPullRequests(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(prs).To(BeEmpty())
+
+	created, err := p.CreatePullRequest(ctx, "chore: promote", "promotion/foo", "main", "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(created.Number).To(Equal(1))
+	g.Expect(created.SourceBranch).To(Equal("promotion/foo"))
+	g.Expect(created.Open).To(BeTrue())
+
+	edited, err := p.EditPullRequest(ctx, created.Number, "chore: promote more", "Changelog")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(edited.Title).To(Equal("chore: promote more"))
+
+	got, err := p.GetPullRequest(ctx, created.Number)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(Equal(edited))
+
+	prs, err = p.ListPullRequests(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(prs).To(ConsistOf(edited))
+
+	_, err = p.GetPullRequest(ctx, 42)
+	g.Expect(err).To(HaveOccurred())
+
+	g.Expect(p.ClosePullRequest(ctx, created.Number, "Superseded.")).To(Succeed())
+	got, err = p.GetPullRequest(ctx, created.Number)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got.Open).To(BeFalse())
+	g.Expect(got.Merged).To(BeFalse())
+	g.Expect(fake.comments).To(ConsistOf("Superseded."))
+
+	prs, err = p.ListPullRequests(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(prs).To(BeEmpty())
+}
+
+func TestGitea_Unauthorized(t *testing.T) {
+	g := NewWithT(t)
+
+	server := httptest.NewServer(&fakeGitea{token: "secret"})
+	defer server.Close()
+
+	p, err := NewGitea(server.URL, "https://gitea.example.com/org/repo", "wrong", server.Client())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = p.ListPullRequests(context.Background())
+	g.Expect(err).To(MatchError(ContainSubstring("status 401")))
+}
+
+func TestGitea_EscapesPath(t *testing.T) {
+	g := NewWithT(t)
+
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		json.NewEncoder(w).Encode(map[string]interface{}{"number": 1, "state": "open"})
+	}))
+	defer server.Close()
+
+	p, err := NewGitea(server.URL, "https://gitea.example.com/my%20org/re%3Fpo.git", "", server.Client())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = p.GetPullRequest(context.Background(), 1)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(p.ClosePullRequest(context.Background(), 1, "superseded")).To(Succeed())
+	g.Expect(paths).To(Equal([]string{
+		"/api/v1/repos/my%20org/re%3Fpo/pulls/1",
+		"/api/v1/repos/my%20org/re%3Fpo/issues/1/comments",
+		"/api/v1/repos/my%20org/re%3Fpo/pulls/1",
+	}))
+}
+
+func TestNewGitea(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		repoURL string
+		wantAPI string
+		wantErr bool
+	}{
+		{
+			name:    "derive base URL",
+			repoURL: "https://gitea.example.com/org/repo.git",
+			wantAPI: "https://gitea.example.com/api/v1",
+		},
+		{
+			name:    "custom base URL",
+			baseURL: "https://git.example.com/gitea/",
+			repoURL: "https://git.example.com/gitea/org/repo",
+			wantAPI: "https://git.example.com/gitea/api/v1",
+		},
+		{
+			name:    "missing repository",
+			repoURL: "https://gitea.example.com/org",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			got, err := NewGitea(tt.baseURL, tt.repoURL, "", nil)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got.apiURL).To(Equal(tt.wantAPI))
+			g.Expect(got.owner).To(Equal("org"))
+			g.Expect(got.repo).To(Equal("repo"))
+		})
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fluxcd/go-git-providers/gitprovider"
+	gogithub "github.com/google/go-github/v49/github"
+	gogitlab "github.com/xanzy/go-gitlab"
+)
+
+// GitProvider implements Provider for all git providers supported by
+// go-git-providers (GitHub, GitLab).
+type GitProvider struct {
+	client gitprovider.Client
+	repo   gitprovider.OrgRepository
+}
+
+// NewGitProvider returns a Provider backed by the given go-git-providers client and repository.
+func NewGitProvider(client gitprovider.Client, repo gitprovider.OrgRepository) *GitProvider {
+	return &GitProvider{client: client, repo: repo}
+}
+
+func (p *GitProvider) ListPullRequests(ctx context.Context) ([]PullRequest, error) {
+	prs, err := p.repo.PullRequests().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var open []PullRequest
+	for _, pr := range prs {
+		if isOpen(pr) {
+			open = append(open, fromGitProvider(pr))
+		}
+	}
+	return open, nil
+}
+
+func (p *GitProvider) GetPullRequest(ctx context.Context, number int) (PullRequest, error) {
+	pr, err := p.repo.PullRequests().Get(ctx, number)
+	if err != nil {
+		return PullRequest{}, err
+	}
+	return fromGitProvider(pr), nil
+}
+
+func (p *GitProvider) CreatePullRequest(ctx context.Context, title, branch, baseBranch, description string) (PullRequest, error) {
+	pr, err := p.repo.PullRequests().Create(ctx, title, branch, baseBranch, description)
+	if err != nil {
+		return PullRequest{}, err
+	}
+	return fromGitProvider(pr), nil
+}
+
+// EditPullRequest edits the pull request through the underlying API client,
+// as go-git-providers can only edit the title of pull requests.
+func (p *GitProvider) EditPullRequest(ctx context.Context, number int, title, description string) (PullRequest, error) {
+	switch raw := p.client.Raw().(type) {
+	case *gogithub.Client:
+		owner, name, err := p.githubRepository()
+		if err != nil {
+			return PullRequest{}, err
+		}
+		if _, _, err := raw.PullRequests.Edit(ctx, owner, name, number, &gogithub.PullRequest{
+			Title: gogithub.String(title),
+			Body:  gogithub.String(description),
+		}); err != nil {
+			return PullRequest{}, err
+		}
+	case *gogitlab.Client:
+		pid, err := p.gitlabProject()
+		if err != nil {
+			return PullRequest{}, err
+		}
+		if _, _, err := raw.MergeRequests.UpdateMergeRequest(pid, number, &gogitlab.UpdateMergeRequestOptions{
+			Title:       gogitlab.String(title),
+			Description: gogitlab.String(description),
+		}, gogitlab.WithContext(ctx)); err != nil {
+			return PullRequest{}, err
+		}
+	default:
+		pr, err := p.repo.PullRequests().Edit(ctx, number, gitprovider.EditOptions{
+			Title: &title,
+		})
+		if err != nil {
+			return PullRequest{}, err
+		}
+		return fromGitProvider(pr), nil
+	}
+	return p.GetPullRequest(ctx, number)
+}
+
+// ClosePullRequest closes the pull request through the underlying API client,
+// as go-git-providers can't close pull requests.
+func (p *GitProvider) ClosePullRequest(ctx context.Context, number int, comment string) error {
+	switch raw := p.client.Raw().(type) {
+	case *gogithub.Client:
+		owner, name, err := p.githubRepository()
+		if err != nil {
+			return err
+		}
+		if comment != "" {
+			if _, _, err := raw.Issues.CreateComment(ctx, owner, name, number, &gogithub.IssueComment{
+				Body: gogithub.String(comment),
+			}); err != nil {
+				return err
+			}
+		}
+		_, _, err = raw.PullRequests.Edit(ctx, owner, name, number, &gogithub.PullRequest{
+			State: gogithub.String("closed"),
+		})
+		return err
+	case *gogitlab.Client:
+		pid, err := p.gitlabProject()
+		if err != nil {
+			return err
+		}
+		if comment != "" {
+			if _, _, err := raw.Notes.CreateMergeRequestNote(pid, number, &gogitlab.CreateMergeRequestNoteOptions{
+				Body: gogitlab.String(comment),
+			}, gogitlab.WithContext(ctx)); err != nil {
+				return err
+			}
+		}
+		_, _, err = raw.MergeRequests.UpdateMergeRequest(pid, number, &gogitlab.UpdateMergeRequestOptions{
+			StateEvent: gogitlab.String("close"),
+		}, gogitlab.WithContext(ctx))
+		return err
+	}
+	return fmt.Errorf("closing pull requests is not supported by %T", p.client.Raw())
+}
+
+// githubRepository returns the owner and name of the GitHub repository.
+func (p *GitProvider) githubRepository() (string, string, error) {
+	apiRepo, ok := p.repo.APIObject().(*gogithub.Repository)
+	if !ok {
+		return "", "", fmt.Errorf("unexpected GitHub repository type %T", p.repo.APIObject())
+	}
+	return apiRepo.GetOwner().GetLogin(), apiRepo.GetName(), nil
+}
+
+// gitlabProject returns the ID of the GitLab project.
+func (p *GitProvider) gitlabProject() (int, error) {
+	project, ok := p.repo.APIObject().(*gogitlab.Project)
+	if !ok {
+		return 0, fmt.Errorf("unexpected GitLab project type %T", p.repo.APIObject())
+	}
+	return project.ID, nil
+}
+
+func fromGitProvider(pr gitprovider.PullRequest) PullRequest {
+	info := pr.Get()
+	return PullRequest{
+		Number:       info.Number,
+		Title:        info.Title,
+		WebURL:       info.WebURL,
+		SourceBranch: info.SourceBranch,
+		Open:         isOpen(pr),
+		Merged:       info.Merged,
+	}
+}
+
+// isOpen returns true if the given pull request (or merge request)
+// has neither been merged nor closed.
+// go-git-providers does not expose the state, so it is read from the
+// underlying API object.
+func isOpen(pr gitprovider.PullRequest) bool {
+	switch apiObj := pr.APIObject().(type) {
+	case *gogithub.PullRequest:
+		return apiObj.GetState() == "open"
+	case *gogitlab.MergeRequest:
+		return apiObj.State == "opened"
+	}
+	return !pr.Get().Merged
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/fluxcd/go-git-providers/gitlab"
+	"github.com/fluxcd/go-git-providers/gitprovider"
+	. "github.com/onsi/gomega"
+)
+
+// fakeGitLab is a minimal stand-in for the project and merge request API of
+// a GitLab server, serving the project "org/repo" with the ID 42.
+type fakeGitLab struct {
+	mu    sync.Mutex
+	token string
+	mrs   []map[string]interface{}
+	notes []string
+}
+
+var fakeGitLabPath = regexp.MustCompile(`^/api/v4/projects/(?:org/repo|42)(?:/merge_requests(?:/(\d+))?(/notes)?)?$`)
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Private-Token") != f.token {
+		http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	m := fakeGitLabPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		http.Error(w, `{"message":"404 Not Found"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var in map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&in)
+	}
+
+	switch {
+	case !regexp.MustCompile(`/merge_requests`).MatchString(r.URL.Path):
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":                  42,
+			"name":                "repo",
+			"path_with_namespace": "org/repo",
+			"default_branch":      "main",
+		})
+	case m[1] == "" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(f.mrs)
+	case m[1] == "" && r.Method == http.MethodPost:
+		n := len(f.mrs) + 1
+		mr := map[string]interface{}{
+			"id":            100 + n,
+			"iid":           n,
+			"title":         in["title"],
+			"description":   in["description"],
+			"source_branch": in["source_branch"],
+			"target_branch": in["target_branch"],
+			"web_url":       fmt.Sprintf("http://%s/org/repo/-/merge_requests/%d", r.Host, n),
+			"state":         "opened",
+		}
+		f.mrs = append(f.mrs, mr)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(mr)
+	default:
+		n, _ := strconv.Atoi(m[1])
+		if n < 1 || n > len(f.mrs) {
+			http.Error(w, `{"message":"404 Not Found"}`, http.StatusNotFound)
+			return
+		}
+		mr := f.mrs[n-1]
+		switch {
+		case m[2] != "" && r.Method == http.MethodPost:
+			f.notes = append(f.notes, in["body"].(string))
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": len(f.notes), "body": in["body"]})
+			return
+		case r.Method == http.MethodPut:
+			for _, key := range []string{"title", "description"} {
+				if v, ok := in[key]; ok {
+					mr[key] = v
+				}
+			}
+			if in["state_event"] == "close" {
+				mr["state"] = "closed"
+			}
+		}
+		json.NewEncoder(w).Encode(mr)
+	}
+}
+
+func (f *fakeGitLab) setState(number int, state string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mrs[number-1]["state"] = state
+}
+
+func TestGitProvider_GitLab(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	fake := &fakeGitLab{token: "secret"}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	// A self-hosted instance is addressed by its base URL.
+	client, err := gitlab.NewClient("secret", "", gitprovider.WithDomain(server.URL))
+	g.Expect(err).ToNot(HaveOccurred())
+	ref, err := gitprovider.ParseOrgRepositoryURL("https://gitlab.example.com/org/repo.git")
+	g.Expect(err).ToNot(HaveOccurred())
+	ref.Domain = client.SupportedDomain()
+	repo, err := client.OrgRepositories().Get(ctx, *ref)
+	g.Expect(err).ToNot(HaveOccurred())
+	p := NewGitProvider(client, repo)
+
+	prs, err := p.ListPullRequests(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(prs).To(BeEmpty())
+
+	created, err := p.CreatePullRequest(ctx, "chore: promote", "promotion/foo", "main", "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(created.Number).To(Equal(1))
+	g.Expect(created.SourceBranch).To(Equal("promotion/foo"))
+	// "opened" merge requests are open.
+	g.Expect(created.Open).To(BeTrue())
+	g.Expect(created.Merged).To(BeFalse())
+
+	// The description is edited along with the title.
+	edited, err := p.EditPullRequest(ctx, created.Number, "chore: promote more", "Changelog")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(edited.Title).To(Equal("chore: promote more"))
+	g.Expect(fake.mrs[0]["description"]).To(Equal("Changelog"))
+
+	prs, err = p.ListPullRequests(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(prs).To(ConsistOf(edited))
+
+	g.Expect(p.ClosePullRequest(ctx, created.Number, "Superseded.")).To(Succeed())
+	got, err := p.GetPullRequest(ctx, created.Number)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got.Open).To(BeFalse())
+	g.Expect(got.Merged).To(BeFalse())
+	g.Expect(fake.notes).To(ConsistOf("Superseded."))
+
+	// Merged and closed merge requests are not listed.
+	merged, err := p.CreatePullRequest(ctx, "chore: promote again", "promotion/bar", "main", "")
+	g.Expect(err).ToNot(HaveOccurred())
+	fake.setState(merged.Number, "merged")
+	got, err = p.GetPullRequest(ctx, merged.Number)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got.Open).To(BeFalse())
+	g.Expect(got.Merged).To(BeTrue())
+	prs, err = p.ListPullRequests(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(prs).To(BeEmpty())
+
+	_, err = p.GetPullRequest(ctx, 42)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package provider abstracts the pull request APIs of the git providers
+// the operator can promote to.
+package provider
+
+import (
+	"context"
+)
+
+// PullRequest describes a pull request (or merge request) on a git provider.
+type PullRequest struct {
+	// Number is the number of the pull request.
+	Number int
+
+	// Title is the title of the pull request.
+	Title string
+
+	// WebURL is the URL of the pull request in the git provider web interface.
+	WebURL string
+
+	// SourceBranch is the branch from which the pull request has been created.
+	SourceBranch string
+
+	// Open is true if the pull request has neither been merged nor closed.
+	Open bool
+
+	// Merged is true if the pull request has been merged.
+	Merged bool
+}
+
+// Provider is the interface to the pull request API of a single repository.
+type Provider interface {
+	// ListPullRequests returns all open pull requests of the repository.
+	ListPullRequests(ctx context.Context) ([]PullRequest, error)
+
+	// GetPullRequest returns the pull request with the given number.
+	GetPullRequest(ctx context.Context, number int) (PullRequest, error)
+
+	// CreatePullRequest opens a new pull request from branch into baseBranch.
+	CreatePullRequest(ctx context.Context, title, branch, baseBranch, description string) (PullRequest, error)
+
+	// EditPullRequest changes the title and description of the pull request with the given number.
+	EditPullRequest(ctx context.Context, number int, title, description string) (PullRequest, error)
+
+	// ClosePullRequest closes the pull request with the given number without merging it.
+	// If comment is not empty, it is added to the pull request before closing it.
+	ClosePullRequest(ctx context.Context, number int, comment string) error
+}
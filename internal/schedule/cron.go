@@ -0,0 +1,55 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Cron is a parsed cron expression with the five fields
+// "minute hour day-of-month month day-of-week".
+type Cron struct {
+	schedule cron.Schedule
+}
+
+// ParseCron parses a standard cron expression like "0 14 * * FRI" or
+// "*/15 9-17 * * 1-5", or a descriptor like "@weekly", with robfig/cron.
+// "@every" and time zone prefixes are rejected, as windows open at fixed
+// times in the time zone of the window.
+func ParseCron(expr string) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if strings.HasPrefix(spec, "@every") || strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+		return nil, fmt.Errorf("invalid cron expression %q: intervals and time zones are not supported", expr)
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	return &Cron{schedule: schedule}, nil
+}
+
+// Next returns the first time after t matching the expression, in the location of t.
+// It returns false if there is none within the next five years,
+// e.g. for "0 0 30 2 *".
+func (c *Cron) Next(t time.Time) (time.Time, bool) {
+	next := c.schedule.Next(t)
+	return next, !next.IsZero()
+}
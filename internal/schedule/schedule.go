@@ -0,0 +1,155 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package schedule tells whether promotions are allowed at a given time, by
+// windows which start at the times of a cron expression and last for a duration.
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	// Embed the time zone database, as the image of the operator may have none.
+	_ "time/tzdata"
+)
+
+// maxSteps bounds the number of windows Blocked skips while looking for the
+// next allowed time, e.g. of back-to-back deny windows.
+const maxSteps = 1000
+
+// Window is a recurring window, which opens at the times of Start in Location,
+// and stays open for Duration.
+type Window struct {
+	// Name describes the window in messages.
+	Name     string
+	Start    *Cron
+	Duration time.Duration
+	Location *time.Location
+}
+
+// NewWindow parses a window opening at the times of the cron expression in the
+// IANA time zone, e.g. "Europe/Vienna", which defaults to UTC if empty.
+func NewWindow(name string, cron string, duration time.Duration, timeZone string) (Window, error) {
+	start, err := ParseCron(cron)
+	if err != nil {
+		return Window{}, err
+	}
+	if duration <= 0 {
+		return Window{}, fmt.Errorf("invalid duration %s of window %q, must be positive", duration, cron)
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+	}
+	return Window{Name: name, Start: start, Duration: duration, Location: loc}, nil
+}
+
+// OpenUntil returns the time the window closes if it is open at t.
+// Overlapping occurrences of the window are merged.
+func (w Window) OpenUntil(t time.Time) (time.Time, bool) {
+	// The window is open if it opened within the last Duration.
+	start, ok := w.Start.Next(t.Add(-w.Duration).In(w.Location))
+	if !ok || start.After(t) {
+		return time.Time{}, false
+	}
+	end := start.Add(w.Duration)
+	for {
+		start, ok = w.Start.Next(start)
+		if !ok || start.After(t) {
+			return end, true
+		}
+		end = start.Add(w.Duration)
+	}
+}
+
+// NextOpen returns the next time the window opens strictly after t.
+func (w Window) NextOpen(t time.Time) (time.Time, bool) {
+	return w.Start.Next(t.In(w.Location))
+}
+
+// Schedule allows promotions while any of the Allow windows is open, or at any
+// time if there are none, unless any of the Deny windows is open.
+type Schedule struct {
+	Allow []Window
+	Deny  []Window
+}
+
+// Block tells why promotions are blocked, and until when.
+type Block struct {
+	// Reason names the window blocking promotions.
+	Reason string
+	// Until is the next time promotions are allowed,
+	// or zero if they aren't within the next years.
+	Until time.Time
+}
+
+// Blocked returns nil if promotions are allowed at t, or else why they are
+// blocked and the next time they are allowed.
+func (s *Schedule) Blocked(t time.Time) *Block {
+	var block *Block
+	for i := 0; i < maxSteps; i++ {
+		reason, next, blocked := s.blockedAt(t)
+		if !blocked {
+			if block != nil {
+				block.Until = t
+			}
+			return block
+		}
+		if block == nil {
+			block = &Block{Reason: reason}
+		}
+		if next.IsZero() {
+			return block
+		}
+		t = next
+	}
+	return block
+}
+
+// blockedAt returns whether promotions are blocked at t, by which window, and
+// the next time worth checking, which is zero if there is none.
+func (s *Schedule) blockedAt(t time.Time) (string, time.Time, bool) {
+	// Skip all open deny windows at once.
+	var denied *Window
+	var until time.Time
+	for i, w := range s.Deny {
+		if end, ok := w.OpenUntil(t); ok {
+			if denied == nil {
+				denied = &s.Deny[i]
+			}
+			if end.After(until) {
+				until = end
+			}
+		}
+	}
+	if denied != nil {
+		return denied.Name, until, true
+	}
+
+	if len(s.Allow) == 0 {
+		return "", time.Time{}, false
+	}
+	var next time.Time
+	for _, w := range s.Allow {
+		if _, ok := w.OpenUntil(t); ok {
+			return "", time.Time{}, false
+		}
+		if open, ok := w.NextOpen(t); ok && (next.IsZero() || open.Before(next)) {
+			next = open
+		}
+	}
+	return "outside of the allowed windows", next, true
+}
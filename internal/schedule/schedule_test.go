@@ -0,0 +1,163 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCron_Next(t *testing.T) {
+	// A Wednesday.
+	from := time.Date(2023, time.March, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{expr: "* * * * *", want: time.Date(2023, time.March, 15, 10, 31, 0, 0, time.UTC)},
+		{expr: "0 14 * * FRI", want: time.Date(2023, time.March, 17, 14, 0, 0, 0, time.UTC)},
+		{expr: "*/15 9-17 * * 1-5", want: time.Date(2023, time.March, 15, 10, 45, 0, 0, time.UTC)},
+		{expr: "0 0 1 dec *", want: time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "30 10 * * 0,6", want: time.Date(2023, time.March, 18, 10, 30, 0, 0, time.UTC)},
+		// Either the day of the month or the day of the week matches.
+		{expr: "0 0 1 * 5", want: time.Date(2023, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 29 2 *", want: time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{expr: "@weekly", want: time.Date(2023, time.March, 19, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c, err := ParseCron(tt.expr)
+			g.Expect(err).ToNot(HaveOccurred())
+			next, ok := c.Next(from)
+			g.Expect(ok).To(BeTrue())
+			g.Expect(next).To(Equal(tt.want))
+		})
+	}
+
+	g := NewWithT(t)
+	c, err := ParseCron("0 0 30 2 *")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, ok := c.Next(from)
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestParseCron(t *testing.T) {
+	g := NewWithT(t)
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "* * * foo *", "@every 1h", "CRON_TZ=Europe/Vienna 0 8 * * *"} {
+		_, err := ParseCron(expr)
+		g.Expect(err).To(HaveOccurred(), expr)
+	}
+}
+
+func TestSchedule_Blocked(t *testing.T) {
+	mustWindow := func(name, cron string, duration time.Duration, timeZone string) Window {
+		w, err := NewWindow(name, cron, duration, timeZone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Schedule{
+		// Business hours in Vienna.
+		Allow: []Window{mustWindow("business hours", "0 8 * * 1-5", 10*time.Hour, "Europe/Vienna")},
+		Deny: []Window{
+			// No promotions on Fridays after 14:00.
+			mustWindow("friday afternoon", "0 14 * * 5", 10*time.Hour, "Europe/Vienna"),
+			// The December freeze.
+			mustWindow("december freeze", "0 0 1 12 *", 31*24*time.Hour, "Europe/Vienna"),
+		},
+	}
+
+	tests := []struct {
+		name       string
+		at         time.Time
+		wantReason string
+		wantUntil  time.Time
+	}{
+		{
+			name: "during business hours",
+			at:   time.Date(2023, time.March, 15, 10, 0, 0, 0, vienna),
+		},
+		{
+			name:       "before business hours",
+			at:         time.Date(2023, time.March, 15, 6, 0, 0, 0, vienna),
+			wantReason: "outside of the allowed windows",
+			wantUntil:  time.Date(2023, time.March, 15, 8, 0, 0, 0, vienna),
+		},
+		{
+			name:       "friday afternoon",
+			at:         time.Date(2023, time.March, 17, 15, 0, 0, 0, vienna),
+			wantReason: "friday afternoon",
+			wantUntil:  time.Date(2023, time.March, 20, 8, 0, 0, 0, vienna),
+		},
+		{
+			name: "in another time zone",
+			// 13:30 in Vienna.
+			at: time.Date(2023, time.March, 17, 12, 30, 0, 0, time.UTC),
+		},
+		{
+			name:       "december freeze",
+			at:         time.Date(2023, time.December, 20, 10, 0, 0, 0, vienna),
+			wantReason: "december freeze",
+			wantUntil:  time.Date(2024, time.January, 1, 8, 0, 0, 0, vienna),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			block := s.Blocked(tt.at)
+			if tt.wantReason == "" {
+				g.Expect(block).To(BeNil())
+				return
+			}
+			g.Expect(block).ToNot(BeNil())
+			g.Expect(block.Reason).To(Equal(tt.wantReason))
+			g.Expect(block.Until.Equal(tt.wantUntil)).To(BeTrue(), block.Until.String())
+		})
+	}
+
+	// Without allow windows, promotions are allowed whenever no deny window is open.
+	g := NewWithT(t)
+	s.Allow = nil
+	g.Expect(s.Blocked(time.Date(2023, time.March, 18, 3, 0, 0, 0, vienna))).To(BeNil())
+	block := s.Blocked(time.Date(2023, time.March, 17, 15, 0, 0, 0, vienna))
+	g.Expect(block.Until.Equal(time.Date(2023, time.March, 18, 0, 0, 0, 0, vienna))).To(BeTrue(), block.Until.String())
+}
+
+func TestNewWindow(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := NewWindow("", "0 0 * * *", time.Hour, "Mars/Olympus_Mons")
+	g.Expect(err).To(HaveOccurred())
+	_, err = NewWindow("", "0 0 * * *", 0, "")
+	g.Expect(err).To(HaveOccurred())
+	w, err := NewWindow("", "0 0 * * *", time.Hour, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(w.Location).To(Equal(time.UTC))
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package semver selects the latest tag in a range of semantic versions,
+// using the constraints of github.com/Masterminds/semver.
+package semver
+
+import (
+	"fmt"
+	"strings"
+
+	mmsemver "github.com/Masterminds/semver/v3"
+)
+
+// Range is a parsed range of semantic versions.
+type Range struct {
+	constraints *mmsemver.Constraints
+}
+
+// ParseRange parses a range in the syntax of Masterminds/semver: comparisons
+// separated by spaces or commas, which all must be satisfied, and alternatives
+// separated by "||", e.g. ">=1.2 <2", "~1.2.3", or "^1.2.0 || ^2.0.0".
+// Pre-release versions are only in ranges which mention a pre-release version.
+func ParseRange(s string) (*Range, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("invalid semver range %q: empty range", s)
+	}
+	c, err := mmsemver.NewConstraint(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid semver range %q: %w", s, err)
+	}
+	return &Range{constraints: c}, nil
+}
+
+// Contains returns true if the version is in the range.
+func (r *Range) Contains(v *mmsemver.Version) bool {
+	return r.constraints.Check(v)
+}
+
+// Latest returns the highest of the tags which is a semantic version in the range.
+// Tags which aren't semantic versions, optionally prefixed with "v", are ignored.
+func Latest(r *Range, tags []string) (string, bool) {
+	var latest string
+	var latestVersion *mmsemver.Version
+	for _, tag := range tags {
+		v, err := mmsemver.StrictNewVersion(strings.TrimPrefix(tag, "v"))
+		if err != nil || !r.Contains(v) {
+			continue
+		}
+		if latestVersion == nil || latestVersion.LessThan(v) {
+			latest, latestVersion = tag, v
+		}
+	}
+	return latest, latestVersion != nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package semver
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestLatest(t *testing.T) {
+	tags := []string{"v1.0.0", "v1.2.0", "v1.2.5", "v1.3.0-rc.1", "v1.10.1", "v2.0.0", "0.3.1", "0.4.0", "latest", "release-1"}
+
+	tests := []struct {
+		rng  string
+		want string
+	}{
+		{rng: ">=1.2.0 <2.0.0", want: "v1.10.1"},
+		{rng: ">=1.2.0, <1.3.0", want: "v1.2.5"},
+		{rng: "~1.2.0", want: "v1.2.5"},
+		{rng: "^1.2", want: "v1.10.1"},
+		{rng: "^0.3.0", want: "0.3.1"},
+		{rng: ">=1 <1.10 !=1.2.5", want: "v1.2.0"},
+		{rng: "1.0.0", want: "v1.0.0"},
+		{rng: "<1.0.0 || >=2", want: "v2.0.0"},
+		{rng: "~1.3.0-rc.0", want: "v1.3.0-rc.1"},
+		{rng: ">=1.2.0 <1.4.0", want: "v1.2.5"},
+		{rng: ">=3.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rng, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r, err := ParseRange(tt.rng)
+			g.Expect(err).ToNot(HaveOccurred())
+			latest, ok := Latest(r, tags)
+			g.Expect(ok).To(Equal(tt.want != ""))
+			g.Expect(latest).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	g := NewWithT(t)
+
+	for _, rng := range []string{"", ">=foo", "1.2.3 ||", ">=1.2.3.4"} {
+		_, err := ParseRange(rng)
+		g.Expect(err).To(HaveOccurred(), rng)
+	}
+}
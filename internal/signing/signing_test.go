@@ -0,0 +1,218 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-billy/v5/memfs"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestOpenPGPKey returns a new armored OpenPGP private key, and its armored public key.
+func newTestOpenPGPKey(t *testing.T) ([]byte, string) {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity("Test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var private, public bytes.Buffer
+	w, err := armor.Encode(&private, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	w, err = armor.Encode(&public, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return private.Bytes(), public.String()
+}
+
+// newTestSSHKey returns a new PEM encoded ed25519 private key, and its public key.
+func newTestSSHKey(t *testing.T) ([]byte, ssh.PublicKey) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), sshPub
+}
+
+// commitTestRepository commits a file to a new repository with key, and returns
+// the repository and the commit.
+func commitTestRepository(t *testing.T, key *Key) (*gogit.Repository, *object.Commit) {
+	t.Helper()
+
+	repo, err := gogit.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := billyutil.WriteFile(wt.Filesystem, "version.yaml", []byte("version: 1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("version.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := Commit(repo, "chore: promote", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, commit
+}
+
+// verifySSHSignature verifies the armored SSH signature of the message
+// against the public key, like "ssh-keygen -Y verify -n git".
+func verifySSHSignature(publicKey ssh.PublicKey, message io.Reader, armored string) error {
+	block, _ := pem.Decode([]byte(armored))
+	if block == nil || block.Type != sshSignaturePEMType {
+		return errors.New("not an armored SSH signature")
+	}
+	if !bytes.HasPrefix(block.Bytes, []byte(sshSignatureMagic)) {
+		return errors.New("invalid SSH signature preamble")
+	}
+	var blob sshSignature
+	if err := ssh.Unmarshal(block.Bytes[len(sshSignatureMagic):], &blob); err != nil {
+		return err
+	}
+	if !bytes.Equal(blob.PublicKey, publicKey.Marshal()) {
+		return errors.New("signed by a different key")
+	}
+	if blob.Namespace != sshSignatureNamespace || blob.HashAlgorithm != sshSignatureHashAlgorithm {
+		return errors.New("unexpected namespace or hash algorithm")
+	}
+	var sig ssh.Signature
+	if err := ssh.Unmarshal(blob.Signature, &sig); err != nil {
+		return err
+	}
+
+	h := sha512.New()
+	if _, err := io.Copy(h, message); err != nil {
+		return err
+	}
+	signedData := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignedData{
+		Namespace:     sshSignatureNamespace,
+		HashAlgorithm: sshSignatureHashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+	return publicKey.Verify(signedData, &sig)
+}
+
+func TestCommit_OpenPGP(t *testing.T) {
+	g := NewWithT(t)
+
+	private, public := newTestOpenPGPKey(t)
+	key, err := ParseKey(private, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(key.OpenPGP).ToNot(BeNil())
+
+	_, commit := commitTestRepository(t, key)
+	g.Expect(commit.PGPSignature).To(HavePrefix("-----BEGIN PGP SIGNATURE-----"))
+	signer, err := commit.Verify(public)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(signer.PrimaryKey.KeyId).To(Equal(key.OpenPGP.PrimaryKey.KeyId))
+
+	// The signature doesn't verify against another key.
+	_, other := newTestOpenPGPKey(t)
+	_, err = commit.Verify(other)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestCommit_SSH(t *testing.T) {
+	g := NewWithT(t)
+
+	private, public := newTestSSHKey(t)
+	key, err := ParseKey(private, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(key.SSH).ToNot(BeNil())
+
+	repo, commit := commitTestRepository(t, key)
+	g.Expect(commit.PGPSignature).To(HavePrefix("-----BEGIN SSH SIGNATURE-----"))
+
+	// The checked out branch points to the signed commit.
+	head, err := repo.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(head.Name()).To(Equal(plumbing.Master))
+	g.Expect(head.Hash()).To(Equal(commit.Hash))
+
+	unsigned := &plumbing.MemoryObject{}
+	g.Expect(commit.EncodeWithoutSignature(unsigned)).To(Succeed())
+	message, err := unsigned.Reader()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(verifySSHSignature(public, message, commit.PGPSignature)).To(Succeed())
+
+	// The signature doesn't verify against another key.
+	_, other := newTestSSHKey(t)
+	message, err = unsigned.Reader()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(verifySSHSignature(other, message, commit.PGPSignature)).ToNot(Succeed())
+}
+
+func TestParseKey(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := ParseKey([]byte("not a key"), nil)
+	g.Expect(err).To(HaveOccurred())
+
+	// Commits are not signed without a key.
+	_, commit := commitTestRepository(t, nil)
+	g.Expect(commit.PGPSignature).To(BeEmpty())
+}
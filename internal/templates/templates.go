@@ -0,0 +1,207 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package templates parses and renders the Go templates of commit messages
+// and pull requests, with a set of Sprig-style helper functions.
+//
+// The helpers are a small subset of Sprig, listed in FuncMap, so templates
+// using other Sprig functions fail to parse.
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// Parse parses the template text with the helper functions.
+// Referencing a missing map key is an error when the template is executed.
+func Parse(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(FuncMap()).Option("missingkey=error").Parse(text)
+}
+
+// Render parses the template text and executes it with data.
+func Render(name, text string, data interface{}) (string, error) {
+	tmpl, err := Parse(name, text)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// FuncMap returns the helper functions available in the templates.
+// They follow the names and argument order of Sprig (https://masterminds.github.io/sprig/),
+// so the piped value is the last argument.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		// Strings
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"title":      title,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"trunc":      trunc,
+		"repeat":     func(count int, s string) string { return strings.Repeat(s, count) },
+		"indent":     indent,
+		"nindent":    func(spaces int, s string) string { return "\n" + indent(spaces, s) },
+		"quote":      func(s string) string { return fmt.Sprintf("%q", s) },
+		"squote":     func(s string) string { return "'" + s + "'" },
+		"code":       func(s string) string { return "`" + s + "`" },
+
+		// Lists
+		"join":      join,
+		"splitList": func(sep, s string) []string { return strings.Split(s, sep) },
+		"first":     first,
+
+		// Regular expressions
+		"regexMatch":      func(regex, s string) (bool, error) { return regexp.MatchString(regex, s) },
+		"regexFind":       regexFind,
+		"regexReplaceAll": regexReplaceAll,
+
+		// Defaults
+		"default": defaultValue,
+		"empty":   empty,
+
+		// Dates
+		"now":  time.Now,
+		"date": func(layout string, t time.Time) string { return t.Format(layout) },
+	}
+}
+
+// title upper-cases the first letter of each word of s, like Sprig's title,
+// which uses the deprecated strings.Title. Words are separated by spaces and
+// punctuation, and the separators are kept as they are.
+func title(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		if isSeparator(prev) {
+			prev = r
+			return unicode.ToTitle(r)
+		}
+		prev = r
+		return r
+	}, s)
+}
+
+// isSeparator reports whether r separates words, as in strings.Title.
+func isSeparator(r rune) bool {
+	if r <= unicode.MaxASCII {
+		switch {
+		case '0' <= r && r <= '9', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', r == '_':
+			return false
+		}
+		return true
+	}
+	if unicode.IsLetter(r) || unicode.IsDigit(r) {
+		return false
+	}
+	return unicode.IsSpace(r)
+}
+
+// trunc truncates s to length characters, or removes length characters
+// from the start of s if length is negative.
+func trunc(length int, s string) string {
+	r := []rune(s)
+	if length < 0 {
+		if -length >= len(r) {
+			return ""
+		}
+		return string(r[len(r)+length:])
+	}
+	if length >= len(r) {
+		return s
+	}
+	return string(r[:length])
+}
+
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// join joins the elements of a list with sep, formatting non-string elements with fmt.
+func join(sep string, list interface{}) string {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return fmt.Sprint(list)
+	}
+	elems := make([]string, v.Len())
+	for i := range elems {
+		elems[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(elems, sep)
+}
+
+func first(list interface{}) interface{} {
+	v := reflect.ValueOf(list)
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Len() == 0 {
+		return nil
+	}
+	return v.Index(0).Interface()
+}
+
+func regexFind(regex, s string) (string, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return "", err
+	}
+	return re.FindString(s), nil
+}
+
+func regexReplaceAll(regex, s, repl string) (string, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return "", err
+	}
+	return re.ReplaceAllString(s, repl), nil
+}
+
+// defaultValue returns value, or def if value is empty.
+func defaultValue(def interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 || empty(value[0]) {
+		return def
+	}
+	return value[0]
+}
+
+// empty returns true if value is the zero value of its type, or an empty collection.
+func empty(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
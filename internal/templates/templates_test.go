@@ -0,0 +1,74 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRender(t *testing.T) {
+	data := map[string]interface{}{
+		"Name":     "Application Version",
+		"Ticket":   "",
+		"Subjects": []string{"app", "settings"},
+		"Message":  "PROJ-123: bump app\n\nDetails",
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: `{{.Name | lower | replace " " "-"}}`, want: "application-version"},
+		{text: `{{.Name | upper | trunc 11}}`, want: "APPLICATION"},
+		{text: `{{.Name | trunc -7}}`, want: "Version"},
+		{text: `{{join ", " .Subjects}}`, want: "app, settings"},
+		{text: `{{first .Subjects | title}}`, want: "App"},
+		{text: `{{"éclair  über app" | title}}`, want: "Éclair  Über App"},
+		{text: `{{"feat(api): add-on v2" | title}}`, want: "Feat(Api): Add-On V2"},
+		{text: `{{.Ticket | default "NOTICKET"}}`, want: "NOTICKET"},
+		{text: `{{regexFind "[A-Z]+-[0-9]+" .Message}}`, want: "PROJ-123"},
+		{text: `{{regexReplaceAll "(?s)\n.*" .Message ""}}`, want: "PROJ-123: bump app"},
+		{text: `{{if hasPrefix "PROJ-" .Message}}yes{{end}}`, want: "yes"},
+		{text: `{{"a\nb" | indent 2}}`, want: "  a\n  b"},
+		{text: `{{.Name | code}}`, want: "`Application Version`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			g := NewWithT(t)
+			got, err := Render("test", tt.text, data)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := Parse("test", `feat: {{.Name | upper}}`)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = Parse("test", `feat: {{.Name`)
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = Parse("test", `feat: {{.Name | shout}}`)
+	g.Expect(err).To(MatchError(ContainSubstring(`function "shout" not defined`)))
+
+	_, err = Render("test", `{{.Missing}}`, map[string]interface{}{})
+	g.Expect(err).To(HaveOccurred())
+}
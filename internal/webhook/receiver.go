@@ -0,0 +1,319 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook implements an HTTP receiver for push webhooks of git
+// providers, which requests the reconciliation of the Environments of the
+// pushed branch and of the Promotions promoting from them.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+// maxPayloadSize is the maximum size of a webhook payload accepted, which is
+// the maximum size of the payloads sent by GitHub.
+const maxPayloadSize = 25 << 20
+
+var log = ctrl.Log.WithName("webhook")
+
+// errUnauthorized is returned if the webhook payload could not be verified.
+var errUnauthorized = errors.New("webhook payload could not be verified")
+
+// Receiver receives push webhooks of GitHub and GitLab.
+type Receiver struct {
+	Client client.Client
+
+	// Addr is the address the receiver listens on, e.g. ":9292".
+	Addr string
+
+	// Secret is the secret configured for the webhooks.
+	// GitHub payloads are verified by their HMAC SHA-256 signature,
+	// GitLab payloads by the secret token sent with them.
+	Secret []byte
+}
+
+// push is a push event to a branch of a repository.
+type push struct {
+	// Ref is the full name of the pushed reference, e.g. "refs/heads/main".
+	Ref string
+	// URLs are the URLs of the pushed repository.
+	URLs []string
+}
+
+// Start serves the receiver until the context is done.
+func (r *Receiver) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              r.Addr,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Info("Starting webhook receiver", "addr", r.Addr)
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// NeedLeaderElection returns false, so every replica of the manager
+// receives webhooks.
+func (r *Receiver) NeedLeaderElection() bool {
+	return false
+}
+
+// ServeHTTP handles a webhook request.
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p, err := r.parse(req.Header, payload)
+	if errors.Is(err, errUnauthorized) {
+		log.Info("Rejected webhook", "reason", err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if p == nil {
+		// Not a push event, e.g. the ping sent by GitHub when the webhook is created.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	environments, promotions, err := r.requestReconciliation(req.Context(), p)
+	if err != nil {
+		log.Error(err, "Unable to request reconciliation", "ref", p.Ref, "urls", p.URLs)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Received push webhook", "ref", p.Ref, "urls", p.URLs, "environments", environments, "promotions", promotions)
+	fmt.Fprintf(w, "requested reconciliation of %d environments and %d promotions\n", len(environments), len(promotions))
+}
+
+// parse verifies the payload and returns the push event it describes,
+// or nil if it is not a push event.
+func (r *Receiver) parse(header http.Header, payload []byte) (*push, error) {
+	switch {
+	case header.Get("X-GitHub-Event") != "":
+		if err := verifyGitHubSignature(r.Secret, header.Get("X-Hub-Signature-256"), payload); err != nil {
+			return nil, err
+		}
+		if header.Get("X-GitHub-Event") != "push" {
+			return nil, nil
+		}
+		return parseGitHubPush(payload)
+	case header.Get("X-Gitlab-Event") != "":
+		if len(r.Secret) == 0 || !hmac.Equal([]byte(header.Get("X-Gitlab-Token")), r.Secret) {
+			return nil, fmt.Errorf("%w: invalid X-Gitlab-Token", errUnauthorized)
+		}
+		if header.Get("X-Gitlab-Event") != "Push Hook" {
+			return nil, nil
+		}
+		return parseGitLabPush(payload)
+	default:
+		return nil, errors.New("unsupported webhook, expected a GitHub or GitLab event")
+	}
+}
+
+// verifyGitHubSignature verifies the X-Hub-Signature-256 header sent by GitHub.
+func verifyGitHubSignature(secret []byte, signature string, payload []byte) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("%w: no secret configured", errUnauthorized)
+	}
+	sum, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("%w: malformed X-Hub-Signature-256", errUnauthorized)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return fmt.Errorf("%w: invalid X-Hub-Signature-256", errUnauthorized)
+	}
+	return nil
+}
+
+func parseGitHubPush(payload []byte) (*push, error) {
+	var event struct {
+		Ref        string `json:"ref"`
+		Repository struct {
+			HTMLURL  string `json:"html_url"`
+			CloneURL string `json:"clone_url"`
+			SSHURL   string `json:"ssh_url"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &push{
+		Ref:  event.Ref,
+		URLs: []string{event.Repository.HTMLURL, event.Repository.CloneURL, event.Repository.SSHURL},
+	}, nil
+}
+
+func parseGitLabPush(payload []byte) (*push, error) {
+	var event struct {
+		Ref     string `json:"ref"`
+		Project struct {
+			WebURL     string `json:"web_url"`
+			GitHTTPURL string `json:"git_http_url"`
+			GitSSHURL  string `json:"git_ssh_url"`
+		} `json:"project"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &push{
+		Ref:  event.Ref,
+		URLs: []string{event.Project.WebURL, event.Project.GitHTTPURL, event.Project.GitSSHURL},
+	}, nil
+}
+
+// requestReconciliation requests the reconciliation of the Environments
+// matching the push, and of the Promotions whose source environment is one of
+// them. It returns the names of the Environments and Promotions.
+func (r *Receiver) requestReconciliation(ctx context.Context, p *push) (environments []string, promotions []string, err error) {
+	environmentList := &promotionsv1alpha1.EnvironmentList{}
+	if err := r.Client.List(ctx, environmentList); err != nil {
+		return nil, nil, err
+	}
+	promotionList := &promotionsv1alpha1.PromotionList{}
+	if err := r.Client.List(ctx, promotionList); err != nil {
+		return nil, nil, err
+	}
+
+	requestedAt := time.Now().Format(time.RFC3339Nano)
+	for i := range environmentList.Items {
+		environment := &environmentList.Items[i]
+		if !matches(environment, p) {
+			continue
+		}
+		if err := requestReconcile(ctx, r.Client, environment, requestedAt); err != nil {
+			return nil, nil, err
+		}
+		environments = append(environments, client.ObjectKeyFromObject(environment).String())
+
+		for j := range promotionList.Items {
+			promotion := &promotionList.Items[j]
+			if promotion.Namespace != environment.Namespace || promotion.Spec.SourceEnvironmentRef == nil ||
+				promotion.Spec.SourceEnvironmentRef.Name != environment.Name {
+				continue
+			}
+			if err := requestReconcile(ctx, r.Client, promotion, requestedAt); err != nil {
+				return nil, nil, err
+			}
+			promotions = append(promotions, client.ObjectKeyFromObject(promotion).String())
+		}
+	}
+	return environments, promotions, nil
+}
+
+// requestReconcile sets the ReconcileRequestAnnotation on the object,
+// which triggers its reconciliation.
+func requestReconcile(ctx context.Context, c client.Client, obj client.Object, requestedAt string) error {
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[promotionsv1alpha1.ReconcileRequestAnnotation] = requestedAt
+	obj.SetAnnotations(annotations)
+	return client.IgnoreNotFound(c.Patch(ctx, obj, patch))
+}
+
+// matches returns true if the push is to the branch of the Environment,
+// or to the tags it checks out.
+func matches(environment *promotionsv1alpha1.Environment, p *push) bool {
+	if !matchesRef(environment, p.Ref) {
+		return false
+	}
+	environmentURL := NormalizeRepositoryURL(environment.Spec.Source.URL)
+	for _, u := range p.URLs {
+		if u != "" && NormalizeRepositoryURL(u) == environmentURL {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesRef returns true if the Environment checks out the pushed ref.
+func matchesRef(environment *promotionsv1alpha1.Environment, ref string) bool {
+	if r := environment.Spec.Source.Reference; r != nil {
+		switch {
+		case r.Commit != "":
+			// Pinned commits don't move.
+			return false
+		case r.SemVer != "":
+			// Any new tag may be the highest in the range.
+			return strings.HasPrefix(ref, "refs/tags/")
+		case r.Tag != "":
+			return ref == "refs/tags/"+r.Tag
+		}
+	}
+	return ref == "refs/heads/"+environment.GetBranch()
+}
+
+// NormalizeRepositoryURL returns the host and path of a repository URL,
+// so that the HTTPS and SSH URLs of a repository are equal,
+// e.g. "github.com/org/repo" for "git@github.com:org/repo.git".
+func NormalizeRepositoryURL(repositoryURL string) string {
+	host, path := "", repositoryURL
+	if u, err := url.Parse(repositoryURL); err == nil && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if at := strings.Index(repositoryURL, "@"); at >= 0 {
+		// SCP-like syntax, e.g. "git@github.com:org/repo.git".
+		if i := strings.Index(repositoryURL[at:], ":"); i >= 0 {
+			host, path = repositoryURL[at+1:at+i], repositoryURL[at+i+1:]
+		}
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return strings.ToLower(host + "/" + path)
+}
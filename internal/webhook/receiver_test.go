@@ -0,0 +1,162 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	promotionsv1alpha1 "github.com/thomasstxyz/gitops-promotions-operator/api/v1alpha1"
+)
+
+func TestReceiver(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(promotionsv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	environment := func(name, url, branch string) *promotionsv1alpha1.Environment {
+		return &promotionsv1alpha1.Environment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: promotionsv1alpha1.EnvironmentSpec{
+				Source: promotionsv1alpha1.Source{URL: url, Reference: &promotionsv1alpha1.GitRepositoryRef{Branch: branch}},
+			},
+		}
+	}
+	promotion := func(name, source string) *promotionsv1alpha1.Promotion {
+		return &promotionsv1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: promotionsv1alpha1.PromotionSpec{
+				SourceEnvironmentRef: &corev1.LocalObjectReference{Name: source},
+				TargetEnvironmentRef: &corev1.LocalObjectReference{Name: "prod"},
+			},
+		}
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		environment("github-dev", "https://github.com/example/fleet", "main"),
+		environment("github-prod", "https://github.com/example/fleet", "prod"),
+		environment("gitlab-dev", "git@gitlab.example.com:platform/fleet.git", "main"),
+		promotion("github-dev-to-prod", "github-dev"),
+		promotion("gitlab-dev-to-prod", "gitlab-dev"),
+	).Build()
+	receiver := &Receiver{Client: c, Secret: []byte("s3cr3t")}
+
+	requested := func(obj client.Object, name string) bool {
+		g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, obj)).To(Succeed())
+		_, ok := obj.GetAnnotations()[promotionsv1alpha1.ReconcileRequestAnnotation]
+		return ok
+	}
+	post := func(payloadFile string, header map[string]string) *httptest.ResponseRecorder {
+		payload, err := os.ReadFile(payloadFile)
+		g.Expect(err).ToNot(HaveOccurred())
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		receiver.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Payloads with an invalid signature are rejected.
+	rec := post("testdata/github-push.json", map[string]string{
+		"X-GitHub-Event":      "push",
+		"X-Hub-Signature-256": "sha256=" + hex.EncodeToString([]byte("invalid")),
+	})
+	g.Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+	g.Expect(requested(&promotionsv1alpha1.Environment{}, "github-dev")).To(BeFalse())
+
+	payload, err := os.ReadFile("testdata/github-push.json")
+	g.Expect(err).ToNot(HaveOccurred())
+	mac := hmac.New(sha256.New, receiver.Secret)
+	mac.Write(payload)
+	rec = post("testdata/github-push.json", map[string]string{
+		"X-GitHub-Event":      "push",
+		"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(mac.Sum(nil)),
+	})
+	g.Expect(rec.Code).To(Equal(http.StatusOK))
+	g.Expect(requested(&promotionsv1alpha1.Environment{}, "github-dev")).To(BeTrue())
+	g.Expect(requested(&promotionsv1alpha1.Promotion{}, "github-dev-to-prod")).To(BeTrue())
+	g.Expect(requested(&promotionsv1alpha1.Environment{}, "github-prod")).To(BeFalse())
+	g.Expect(requested(&promotionsv1alpha1.Environment{}, "gitlab-dev")).To(BeFalse())
+	g.Expect(requested(&promotionsv1alpha1.Promotion{}, "gitlab-dev-to-prod")).To(BeFalse())
+
+	rec = post("testdata/gitlab-push.json", map[string]string{
+		"X-Gitlab-Event": "Push Hook",
+		"X-Gitlab-Token": "wrong",
+	})
+	g.Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+
+	rec = post("testdata/gitlab-push.json", map[string]string{
+		"X-Gitlab-Event": "Push Hook",
+		"X-Gitlab-Token": "s3cr3t",
+	})
+	g.Expect(rec.Code).To(Equal(http.StatusOK))
+	g.Expect(requested(&promotionsv1alpha1.Environment{}, "gitlab-dev")).To(BeTrue())
+	g.Expect(requested(&promotionsv1alpha1.Promotion{}, "gitlab-dev-to-prod")).To(BeTrue())
+	g.Expect(requested(&promotionsv1alpha1.Environment{}, "github-prod")).To(BeFalse())
+}
+
+func TestNormalizeRepositoryURL(t *testing.T) {
+	g := NewWithT(t)
+
+	for _, u := range []string{
+		"https://github.com/Example/fleet",
+		"https://github.com/example/fleet.git",
+		"https://token@github.com/example/fleet/",
+		"ssh://git@github.com:22/example/fleet.git",
+		"git@github.com:example/fleet.git",
+	} {
+		g.Expect(NormalizeRepositoryURL(u)).To(Equal("github.com/example/fleet"), u)
+	}
+}
+
+func TestMatchesRef(t *testing.T) {
+	g := NewWithT(t)
+
+	environment := func(ref *promotionsv1alpha1.GitRepositoryRef) *promotionsv1alpha1.Environment {
+		return &promotionsv1alpha1.Environment{
+			Spec: promotionsv1alpha1.EnvironmentSpec{
+				Source: promotionsv1alpha1.Source{Reference: ref},
+			},
+		}
+	}
+
+	g.Expect(matchesRef(environment(nil), "refs/heads/master")).To(BeTrue())
+	g.Expect(matchesRef(environment(nil), "refs/tags/v1.0.0")).To(BeFalse())
+	g.Expect(matchesRef(environment(&promotionsv1alpha1.GitRepositoryRef{Tag: "v1.0.0"}), "refs/tags/v1.0.0")).To(BeTrue())
+	g.Expect(matchesRef(environment(&promotionsv1alpha1.GitRepositoryRef{Tag: "v1.0.0"}), "refs/heads/master")).To(BeFalse())
+	g.Expect(matchesRef(environment(&promotionsv1alpha1.GitRepositoryRef{SemVer: ">=1.0.0"}), "refs/tags/v1.1.0")).To(BeTrue())
+	g.Expect(matchesRef(environment(&promotionsv1alpha1.GitRepositoryRef{Branch: "main", Commit: "0123456789abcdef0123456789abcdef01234567"}), "refs/heads/main")).To(BeFalse())
+}
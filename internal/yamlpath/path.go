@@ -0,0 +1,219 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yamlpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// segment is a single step of a path, either a mapping key or a sequence index.
+type segment struct {
+	key   string
+	index int
+}
+
+func (s segment) isIndex() bool {
+	return s.index >= 0
+}
+
+// parse parses a path such as "spec.template.spec.containers[0].image" into
+// its segments. Keys containing dots can be quoted within brackets, e.g.
+// `metadata.annotations["app.kubernetes.io/version"]`.
+func parse(path string) ([]segment, error) {
+	p := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if p == "" {
+		return nil, fmt.Errorf("invalid path %q: path is empty", path)
+	}
+
+	var segments []segment
+	for len(p) > 0 {
+		if p[0] == '[' {
+			if len(p) > 1 && (p[1] == '"' || p[1] == '\'') {
+				end := strings.IndexByte(p[2:], p[1])
+				if end < 0 || len(p) <= end+3 || p[end+3] != ']' {
+					return nil, fmt.Errorf("invalid path %q: unterminated quoted key", path)
+				}
+				segments = append(segments, segment{key: p[2 : end+2], index: -1})
+				p = p[end+4:]
+			} else {
+				end := strings.IndexByte(p, ']')
+				if end < 0 {
+					return nil, fmt.Errorf("invalid path %q: unterminated index", path)
+				}
+				index, err := strconv.Atoi(p[1:end])
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid path %q: invalid index %q", path, p[1:end])
+				}
+				segments = append(segments, segment{index: index})
+				p = p[end+1:]
+			}
+			if len(p) > 0 && p[0] != '.' && p[0] != '[' {
+				return nil, fmt.Errorf("invalid path %q: unexpected %q after index", path, p[0])
+			}
+		} else {
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid path %q: empty key", path)
+			}
+			segments = append(segments, segment{key: p[:end], index: -1})
+			p = p[end:]
+		}
+
+		if len(p) > 0 && p[0] == '.' {
+			p = p[1:]
+			if p == "" {
+				return nil, fmt.Errorf("invalid path %q: empty key", path)
+			}
+		}
+	}
+
+	return segments, nil
+}
+
+// child returns the value and, for mappings, the key node of the given
+// segment below node. It returns nil if there is no such child.
+func child(node *yaml.Node, s segment) (value *yaml.Node, key *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		if s.isIndex() {
+			return nil, nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == s.key {
+				return node.Content[i+1], node.Content[i]
+			}
+		}
+	case yaml.SequenceNode:
+		if s.isIndex() && s.index < len(node.Content) {
+			return node.Content[s.index], nil
+		}
+	}
+	return nil, nil
+}
+
+// lookup returns the node at the given path of the document, following
+// aliases. It returns nil if the path does not exist.
+func lookup(doc *yaml.Node, segments []segment) *yaml.Node {
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	node := doc.Content[0]
+	for _, s := range segments {
+		for node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+		if node, _ = child(node, s); node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// setNode sets the value at the given path of the document, adding missing
+// mapping keys along the way. An index equal to the length of a sequence
+// appends the value to it.
+func setNode(doc *yaml.Node, segments []segment, value *yaml.Node) error {
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+
+	node := doc.Content[0]
+	for i, s := range segments {
+		last := i == len(segments)-1
+
+		// An empty value, e.g. "key:", becomes a mapping.
+		if !s.isIndex() && node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+			*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+
+		switch {
+		case node.Kind == yaml.AliasNode:
+			return fmt.Errorf("cannot set %s: path goes through the alias %s", format(segments), node.Value)
+		case s.isIndex():
+			if node.Kind != yaml.SequenceNode {
+				return fmt.Errorf("cannot set %s: %s is not a sequence", format(segments), format(segments[:i]))
+			}
+			if s.index > len(node.Content) || (s.index == len(node.Content) && !last) {
+				return fmt.Errorf("cannot set %s: index %d out of range", format(segments), s.index)
+			}
+			if s.index == len(node.Content) {
+				node.Content = append(node.Content, value)
+				return nil
+			}
+			if last {
+				replace(node.Content[s.index], value)
+				return nil
+			}
+			node = node.Content[s.index]
+		default:
+			if node.Kind != yaml.MappingNode {
+				return fmt.Errorf("cannot set %s: %s is not a mapping", format(segments), format(segments[:i]))
+			}
+			next, _ := child(node, s)
+			if next == nil {
+				if !last && segments[i+1].isIndex() {
+					return fmt.Errorf("cannot set %s: %s does not exist", format(segments), format(segments[:i+1]))
+				}
+				next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s.key}, next)
+			}
+			if last {
+				replace(next, value)
+				return nil
+			}
+			node = next
+		}
+	}
+	return nil
+}
+
+// replace replaces the node with the value. The anchor of the node is kept,
+// so aliases refer to the new value.
+func replace(node *yaml.Node, value *yaml.Node) {
+	anchor := node.Anchor
+	*node = *value
+	node.Anchor = anchor
+}
+
+// format formats the segments as a path.
+func format(segments []segment) string {
+	var b strings.Builder
+	for _, s := range segments {
+		switch {
+		case s.isIndex():
+			fmt.Fprintf(&b, "[%d]", s.index)
+		case strings.ContainsAny(s.key, ".[]"):
+			fmt.Fprintf(&b, "[%q]", s.key)
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(s.key)
+		}
+	}
+	if b.Len() == 0 {
+		return "."
+	}
+	return b.String()
+}
@@ -0,0 +1,534 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yamlpath
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// file is the text of a YAML file along with its decoded documents.
+type file struct {
+	data []byte
+	// lines holds the offset of the start of every line.
+	lines []int
+	docs  []*yaml.Node
+}
+
+func newFile(data []byte) (*file, error) {
+	docs, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	f := &file{data: data, lines: []int{0}, docs: docs}
+	for i, c := range data {
+		if c == '\n' {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+	return f, nil
+}
+
+// text is a rendered value. Its first line is written where the value
+// starts, the following lines are indented relative to column zero.
+type text struct {
+	lines []string
+	// block is set for block collections, which can't follow a mapping
+	// key on the same line.
+	block bool
+	kind  yaml.Kind
+}
+
+func isBlock(node *yaml.Node) bool {
+	return (node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode) &&
+		node.Style&yaml.FlowStyle == 0 && len(node.Content) > 0
+}
+
+// render renders the node with the encoder.
+func render(node *yaml.Node) (*text, error) {
+	out, err := encode([]*yaml.Node{node})
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	if isBlockScalar(node) {
+		lines = dedent(lines)
+	}
+	return &text{lines: lines, block: isBlock(node), kind: node.Kind}, nil
+}
+
+func isBlockScalar(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0
+}
+
+// dedent removes the common indentation of the lines of a literal or folded
+// scalar following its header, so they can be indented below any key.
+func dedent(lines []string) []string {
+	indent := -1
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n := len(line) - len(strings.TrimLeft(line, " ")); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	out := []string{lines[0]}
+	for _, line := range lines[1:] {
+		if len(line) >= indent && indent > 0 {
+			line = line[indent:]
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// hasAnchors tells whether the node or any node below it is an alias or has
+// an anchor, which can't be copied as written into another file.
+func hasAnchors(node *yaml.Node) bool {
+	if node.Kind == yaml.AliasNode || node.Anchor != "" {
+		return true
+	}
+	for _, c := range node.Content {
+		if hasAnchors(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// text returns the node as written in the file.
+func (f *file) text(node *yaml.Node) (*text, bool) {
+	if hasAnchors(node) {
+		return nil, false
+	}
+	start, end := f.offset(node.Line, node.Column), f.end(node, false)
+	if start < 0 || end < 0 {
+		return nil, false
+	}
+	if isBlockScalar(node) {
+		lines := strings.Split(strings.ReplaceAll(string(f.data[start:end]), "\r", ""), "\n")
+		return &text{lines: dedent(lines), kind: node.Kind}, true
+	}
+
+	indent := node.Column - 1
+	lines := strings.Split(string(f.data[start:end]), "\n")
+	for i := range lines {
+		line := strings.TrimRight(lines[i], "\r")
+		switch {
+		case i == 0:
+		case strings.TrimSpace(line) == "":
+			line = ""
+		case len(line) < indent || strings.TrimLeft(line[:indent], " ") != "":
+			return nil, false
+		default:
+			line = line[indent:]
+		}
+		lines[i] = line
+	}
+	return &text{lines: lines, block: isBlock(node), kind: node.Kind}, true
+}
+
+// offset returns the offset of the given line and column, which are
+// counted from one like in yaml.Node. It returns -1 if there's no such line.
+func (f *file) offset(line, column int) int {
+	if line < 1 || line > len(f.lines) {
+		return -1
+	}
+	o := f.lines[line-1]
+	for c := 1; c < column && o < len(f.data); c++ {
+		_, size := utf8.DecodeRune(f.data[o:])
+		o += size
+	}
+	return o
+}
+
+// lineEnd returns the offset of the end of the line containing offset o,
+// excluding the line break.
+func (f *file) lineEnd(o int) int {
+	for o < len(f.data) && f.data[o] != '\n' && f.data[o] != '\r' {
+		o++
+	}
+	return o
+}
+
+// end returns the offset right after the text of the node, or -1 if it
+// can't be determined. flow tells whether the node is inside a flow collection.
+func (f *file) end(node *yaml.Node, flow bool) int {
+	start := f.offset(node.Line, node.Column)
+	if start < 0 {
+		return -1
+	}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Value == "" && node.ShortTag() == "!!null" {
+			return start
+		}
+		var end int
+		switch node.Style {
+		case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+			end = f.quotedEnd(start)
+		case yaml.LiteralStyle, yaml.FoldedStyle:
+			end = f.blockScalarEnd(start)
+		default:
+			end = f.plainEnd(start, flow)
+		}
+		if end < 0 {
+			return -1
+		}
+		// Make sure the text is the whole scalar, e.g. not only the first
+		// line of a multi-line plain scalar. The line break ending a block
+		// scalar belongs to its value.
+		scalar := append([]byte{}, f.data[start:end]...)
+		if isBlockScalar(node) {
+			scalar = append(scalar, '\n')
+		}
+		var n yaml.Node
+		if err := yaml.Unmarshal(scalar, &n); err != nil ||
+			len(n.Content) != 1 || n.Content[0].Value != node.Value {
+			return -1
+		}
+		return end
+	case yaml.AliasNode:
+		return f.plainEnd(start, true)
+	case yaml.MappingNode, yaml.SequenceNode:
+		flow = flow || node.Style&yaml.FlowStyle != 0
+		end := start
+		for i, c := range node.Content {
+			if node.Kind == yaml.MappingNode && i%2 == 0 {
+				// Keys come before their values.
+				continue
+			}
+			e := f.end(c, flow)
+			if e < 0 {
+				return -1
+			}
+			if e > end {
+				end = e
+			}
+		}
+		if node.Style&yaml.FlowStyle == 0 {
+			// Include the comment at the end of the last line.
+			i := end
+			for i < len(f.data) && (f.data[i] == ' ' || f.data[i] == '\t') {
+				i++
+			}
+			if i < len(f.data) && f.data[i] == '#' {
+				return f.lineEnd(i)
+			}
+			return end
+		}
+		// Find the closing bracket of the flow collection.
+		closing := byte('}')
+		if node.Kind == yaml.SequenceNode {
+			closing = ']'
+		}
+		if len(node.Content) == 0 {
+			end++
+		}
+		for ; end < len(f.data); end++ {
+			switch f.data[end] {
+			case closing:
+				return end + 1
+			case ' ', '\t', '\r', '\n', ',':
+			default:
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+// quotedEnd returns the offset after the quoted scalar starting at offset o.
+func (f *file) quotedEnd(o int) int {
+	quote := f.data[o]
+	if quote != '"' && quote != '\'' {
+		return -1
+	}
+	for i := o + 1; i < len(f.data); i++ {
+		switch {
+		case quote == '"' && f.data[i] == '\\':
+			i++
+		case f.data[i] == quote:
+			if quote == '\'' && i+1 < len(f.data) && f.data[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// plainEnd returns the offset after the plain scalar starting at offset o,
+// which ends at the end of the line or before a comment.
+func (f *file) plainEnd(o int, flow bool) int {
+	end := o
+	for i := o; i < len(f.data); i++ {
+		c := f.data[i]
+		if c == '\n' || c == '\r' ||
+			(c == '#' && i > o && (f.data[i-1] == ' ' || f.data[i-1] == '\t')) ||
+			(flow && strings.IndexByte(",[]{}", c) >= 0) {
+			break
+		}
+		if c != ' ' && c != '\t' {
+			end = i + 1
+		}
+	}
+	return end
+}
+
+// blockScalarEnd returns the offset after the literal or folded scalar whose
+// header starts at offset o. It ends with the last line which is indented at
+// least as much as its first line.
+func (f *file) blockScalarEnd(o int) int {
+	end := f.lineEnd(o)
+	indent := -1
+	for i := end; i < len(f.data); {
+		// Skip the line break.
+		if f.data[i] == '\r' {
+			i++
+		}
+		if i < len(f.data) && f.data[i] == '\n' {
+			i++
+		}
+		lineStart, lineEnd := i, f.lineEnd(i)
+		i = lineEnd
+		line := string(f.data[lineStart:lineEnd])
+		if strings.TrimSpace(line) == "" {
+			if lineEnd == len(f.data) {
+				break
+			}
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " "))
+		if indent < 0 {
+			indent = n
+		}
+		if n < indent {
+			break
+		}
+		end = lineEnd
+	}
+	return end
+}
+
+// colon returns the offset after the colon following the mapping key.
+func (f *file) colon(key *yaml.Node) int {
+	start := f.offset(key.Line, key.Column)
+	if start < 0 {
+		return -1
+	}
+	i := start
+	if key.Style == yaml.DoubleQuotedStyle || key.Style == yaml.SingleQuotedStyle {
+		if i = f.quotedEnd(start); i < 0 {
+			return -1
+		}
+	}
+	for ; i < len(f.data); i++ {
+		switch f.data[i] {
+		case ':':
+			if i+1 == len(f.data) || strings.IndexByte(" \t\r\n", f.data[i+1]) >= 0 {
+				return i + 1
+			}
+		case '\n':
+			return -1
+		}
+	}
+	return -1
+}
+
+// splice returns the file with the value at the given path of the document
+// replaced by the rendered value. It reports false if the value can't be
+// written in place.
+func (f *file) splice(doc *yaml.Node, segments []segment, value *text) ([]byte, bool) {
+	if len(doc.Content) == 0 {
+		return nil, false
+	}
+
+	var parent, key *yaml.Node
+	var flow bool
+	node := doc.Content[0]
+	for i, s := range segments {
+		if node.Kind == yaml.AliasNode {
+			return nil, false
+		}
+		c, k := child(node, s)
+		if c == nil {
+			if flow {
+				return nil, false
+			}
+			return f.insert(node, segments[i:], value)
+		}
+		flow = flow || node.Style&yaml.FlowStyle != 0
+		parent, key, node = node, k, c
+	}
+
+	start, end := f.offset(node.Line, node.Column), f.end(node, flow)
+	if start < 0 || end < 0 {
+		return nil, false
+	}
+	// The anchor of the value is written before it, so aliases of it remain valid.
+	var anchor string
+	if node.Anchor != "" {
+		anchor = "&" + node.Anchor
+	}
+
+	switch {
+	case node.Kind == yaml.ScalarNode && !isBlockScalar(node) && !value.block && len(value.lines) == 1:
+		// Replace the scalar, keeping everything around it.
+		return f.replace(start, end, withAnchor(anchor, value.lines[0])), true
+	case flow:
+		return nil, false
+	case parent != nil && parent.Kind == yaml.MappingNode:
+		// Replace everything after the colon of the key.
+		colon := f.colon(key)
+		if colon < 0 || colon > start {
+			return nil, false
+		}
+		keyIndent := key.Column - 1
+		var b strings.Builder
+		if anchor != "" {
+			b.WriteString(" " + anchor)
+		}
+		if value.block {
+			indent := valueIndent(keyIndent, value)
+			if isBlock(node) && (node.Column-1 > keyIndent || value.kind == yaml.SequenceNode) {
+				// Keep the indentation of the current value.
+				indent = node.Column - 1
+			}
+			for _, line := range value.lines {
+				b.WriteString("\n")
+				writeIndented(&b, line, indent)
+			}
+		} else {
+			b.WriteString(" ")
+			b.WriteString(value.lines[0])
+			for _, line := range value.lines[1:] {
+				b.WriteString("\n")
+				writeIndented(&b, line, keyIndent+2)
+			}
+		}
+		return f.replace(colon, end, b.String()), true
+	default:
+		// The value of a block sequence item, or of the document itself.
+		var b strings.Builder
+		b.WriteString(withAnchor(anchor, value.lines[0]))
+		for _, line := range value.lines[1:] {
+			b.WriteString("\n")
+			writeIndented(&b, line, node.Column-1)
+		}
+		return f.replace(start, end, b.String()), true
+	}
+}
+
+// insert returns the file with the missing keys of the path added to the
+// end of the block mapping node and the rendered value set on the last key,
+// or with the rendered value appended to the block sequence node.
+// It reports false if the value can't be added in place.
+func (f *file) insert(node *yaml.Node, segments []segment, value *text) ([]byte, bool) {
+	if !isBlock(node) {
+		return nil, false
+	}
+	end := f.end(node, false)
+	if end < 0 {
+		return nil, false
+	}
+	// Add the keys or item after the line of the last value.
+	at := f.lineEnd(end)
+
+	if node.Kind == yaml.SequenceNode {
+		if len(segments) != 1 || segments[0].index != len(node.Content) {
+			return nil, false
+		}
+		indent := node.Column - 1
+		var b strings.Builder
+		b.WriteString("\n")
+		writeIndented(&b, "- "+value.lines[0], indent)
+		for _, line := range value.lines[1:] {
+			b.WriteString("\n")
+			writeIndented(&b, line, indent+2)
+		}
+		return f.replace(at, at, b.String()), true
+	}
+
+	indent := node.Content[0].Column - 1
+	var b strings.Builder
+	for i, s := range segments {
+		if s.isIndex() {
+			return nil, false
+		}
+		key, err := render(&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s.key})
+		if err != nil || len(key.lines) != 1 {
+			return nil, false
+		}
+		b.WriteString("\n")
+		writeIndented(&b, key.lines[0]+":", indent+2*i)
+	}
+	keyIndent := indent + 2*(len(segments)-1)
+	if value.block {
+		for _, line := range value.lines {
+			b.WriteString("\n")
+			writeIndented(&b, line, valueIndent(keyIndent, value))
+		}
+	} else {
+		b.WriteString(" ")
+		b.WriteString(value.lines[0])
+		for _, line := range value.lines[1:] {
+			b.WriteString("\n")
+			writeIndented(&b, line, keyIndent+2)
+		}
+	}
+
+	return f.replace(at, at, b.String()), true
+}
+
+// valueIndent returns the indentation of a block value of a mapping key.
+// Block sequences are not indented, like kubectl and kustomize write them.
+func valueIndent(keyIndent int, value *text) int {
+	if value.kind == yaml.SequenceNode {
+		return keyIndent
+	}
+	return keyIndent + 2
+}
+
+// withAnchor returns the line prefixed with the anchor, if any.
+func withAnchor(anchor, line string) string {
+	if anchor == "" {
+		return line
+	}
+	return anchor + " " + line
+}
+
+func writeIndented(b *strings.Builder, line string, indent int) {
+	if line != "" {
+		b.WriteString(strings.Repeat(" ", indent))
+	}
+	b.WriteString(line)
+}
+
+// replace returns a copy of the file with the text between start and end
+// replaced by s.
+func (f *file) replace(start, end int, s string) []byte {
+	out := make([]byte, 0, len(f.data)-(end-start)+len(s))
+	out = append(out, f.data[:start]...)
+	out = append(out, s...)
+	return append(out, f.data[end:]...)
+}
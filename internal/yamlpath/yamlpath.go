@@ -0,0 +1,226 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package yamlpath reads and writes values at paths such as
+// "spec.template.spec.containers[0].image" in YAML files.
+//
+// Values are written by editing the text of the file in place, so that
+// comments and formatting outside of the written value are kept intact.
+// Changes which can't be made that way, e.g. adding a key to a flow
+// mapping, fail with ErrUnsupported rather than reformatting the file.
+package yamlpath
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotFound is returned when a path does not exist.
+var ErrNotFound = errors.New("path not found")
+
+// ErrUnsupported is returned when a value can't be written without
+// reformatting the file.
+var ErrUnsupported = errors.New("cannot be written in place")
+
+// Get returns the value at the given path in the first document of data
+// which contains it.
+func Get(data []byte, path string) (*yaml.Node, error) {
+	segments, err := parse(path)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if node := lookup(doc, segments); node != nil {
+			return detach(node), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+}
+
+// Set sets the value at the given path in data and returns the result.
+// Missing mapping keys along the path are added, and an index equal to
+// the length of a sequence appends the value to it.
+func Set(data []byte, path string, value *yaml.Node) ([]byte, error) {
+	value = detach(value)
+	rendered, err := render(value)
+	if err != nil {
+		return nil, err
+	}
+	return set(data, path, value, rendered)
+}
+
+// Copy copies the value at sourcePath in source to targetPath in target,
+// and returns the resulting target. The value is copied as written in
+// source, including its formatting and comments.
+func Copy(source []byte, sourcePath string, target []byte, targetPath string) ([]byte, error) {
+	segments, err := parse(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	src, err := newFile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var node *yaml.Node
+	for _, doc := range src.docs {
+		if node = lookup(doc, segments); node != nil {
+			break
+		}
+	}
+	if node == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, sourcePath)
+	}
+
+	value := detach(node)
+	rendered, ok := src.text(node)
+	if !ok {
+		if rendered, err = render(value); err != nil {
+			return nil, err
+		}
+	}
+	return set(target, targetPath, value, rendered)
+}
+
+func set(data []byte, path string, value *yaml.Node, rendered *text) ([]byte, error) {
+	segments, err := parse(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := newFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The expected result, which the edited text is checked against.
+	expected, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(expected) == 0 {
+		expected = []*yaml.Node{{Kind: yaml.DocumentNode}}
+	}
+	i := 0
+	for j, doc := range expected {
+		if lookup(doc, segments) != nil {
+			i = j
+			break
+		}
+	}
+	if err := setNode(expected[i], segments, value); err != nil {
+		return nil, err
+	}
+
+	// A file without any content has nothing to keep.
+	if len(f.docs) == 0 && len(bytes.TrimSpace(data)) == 0 {
+		return encode(expected)
+	}
+	if i < len(f.docs) {
+		if out, ok := f.splice(f.docs[i], segments, rendered); ok {
+			if docs, err := decode(out); err == nil && equalAll(docs, expected) {
+				return out, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, path)
+}
+
+// decode decodes all documents of data.
+func decode(data []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		doc := &yaml.Node{}
+		if err := decoder.Decode(doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// encode encodes the documents with an indentation of two spaces.
+func encode(docs []*yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// detach returns a copy of the node with aliases resolved and anchors
+// removed, so it can be used in another document.
+func detach(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	out := *node
+	out.Anchor = ""
+	out.Content = nil
+	for _, c := range node.Content {
+		out.Content = append(out.Content, detach(c))
+	}
+	return &out
+}
+
+// equal tells whether the nodes have the same content, regardless of
+// their formatting and comments.
+func equal(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Anchor != b.Anchor || len(a.Content) != len(b.Content) {
+		return false
+	}
+	switch a.Kind {
+	case yaml.ScalarNode:
+		return a.ShortTag() == b.ShortTag() && a.Value == b.Value
+	case yaml.AliasNode:
+		return a.Value == b.Value
+	}
+	for i := range a.Content {
+		if !equal(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalAll(a, b []*yaml.Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,319 @@
+/*
+Copyright 2023 Thomas Stadler <thomas@thomasst.xyz>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yamlpath
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app # the app
+spec:
+  # keep replicas per environment
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: app
+        image: ghcr.io/example/app:1.0.0 # {"$imagepolicy": "app"}
+        resources:
+          limits:
+            memory: 128Mi
+`
+
+func TestCopy(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		sourcePath string
+		target     string
+		targetPath string
+		want       string
+	}{
+		{
+			name:       "scalar",
+			source:     "spec:\n  template:\n    spec:\n      containers:\n      - image: ghcr.io/example/app:1.1.0\n",
+			sourcePath: "spec.template.spec.containers[0].image",
+			target:     deployment,
+			targetPath: "spec.template.spec.containers[0].image",
+			want: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app # the app
+spec:
+  # keep replicas per environment
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: app
+        image: ghcr.io/example/app:1.1.0 # {"$imagepolicy": "app"}
+        resources:
+          limits:
+            memory: 128Mi
+`,
+		},
+		{
+			name:       "scalar to another path",
+			source:     "images:\n- name: app\n  newTag: \"1.1\" # release\n",
+			sourcePath: "images[0].newTag",
+			target:     "app:\n  # the tag\n  tag: 1.0 # old\n",
+			targetPath: ".app.tag",
+			want:       "app:\n  # the tag\n  tag: \"1.1\" # old\n",
+		},
+		{
+			name:       "mapping replaces mapping",
+			source:     "resources:\n    limits:\n        memory: 256Mi # more\n        cpu: 1\n",
+			sourcePath: "resources",
+			target:     deployment,
+			targetPath: "spec.template.spec.containers[0].resources",
+			want: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app # the app
+spec:
+  # keep replicas per environment
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: app
+        image: ghcr.io/example/app:1.0.0 # {"$imagepolicy": "app"}
+        resources:
+          limits:
+              memory: 256Mi # more
+              cpu: 1
+`,
+		},
+		{
+			name:       "mapping replaces scalar",
+			source:     "limits:\n  memory: 256Mi\n",
+			sourcePath: "limits",
+			target:     "a: 1\nlimits: none # comment\nb: 2\n",
+			targetPath: "limits",
+			want:       "a: 1\nlimits:\n  memory: 256Mi # comment\nb: 2\n",
+		},
+		{
+			name:       "scalar replaces mapping",
+			source:     "limits: none\n",
+			sourcePath: "limits",
+			target:     "a: 1\nlimits:\n  memory: 256Mi\nb: 2\n",
+			targetPath: "limits",
+			want:       "a: 1\nlimits: none\nb: 2\n",
+		},
+		{
+			name:       "sequence keeps indentation",
+			source:     "args:\n  - --verbose\n  - --port=8080\n",
+			sourcePath: "args",
+			target:     "spec:\n  args:\n  - --quiet\n  replicas: 1\n",
+			targetPath: "spec.args",
+			want:       "spec:\n  args:\n  - --verbose\n  - --port=8080\n  replicas: 1\n",
+		},
+		{
+			name:       "sequence item",
+			source:     "- name: app\n  image: app:2\n",
+			sourcePath: "[0]",
+			target:     "containers:\n- name: app\n  image: app:1\n- name: sidecar\n",
+			targetPath: "containers[0]",
+			want:       "containers:\n- name: app\n  image: app:2\n- name: sidecar\n",
+		},
+		{
+			name:       "literal scalar",
+			source:     "config: |\n  a=1\n  b=2\n",
+			sourcePath: "config",
+			target:     "data:\n  config: |\n    a=0\n  other: x\n",
+			targetPath: "data.config",
+			want:       "data:\n  config: |\n    a=1\n    b=2\n  other: x\n",
+		},
+		{
+			name:       "missing keys are added",
+			source:     "tag: 1.1.0\n",
+			sourcePath: "tag",
+			target:     "image:\n  repository: app # repo\nreplicas: 1\n",
+			targetPath: "image.pullPolicy.tag",
+			want:       "image:\n  repository: app # repo\n  pullPolicy:\n    tag: 1.1.0\nreplicas: 1\n",
+		},
+		{
+			name:       "quoted key",
+			source:     "metadata:\n  labels:\n    app.kubernetes.io/version: 1.1.0\n",
+			sourcePath: `metadata.labels["app.kubernetes.io/version"]`,
+			target:     "metadata:\n  labels:\n    app.kubernetes.io/version: 1.0.0\n",
+			targetPath: `metadata.labels['app.kubernetes.io/version']`,
+			want:       "metadata:\n  labels:\n    app.kubernetes.io/version: 1.1.0\n",
+		},
+		{
+			name:       "multiple documents",
+			source:     "kind: Service\n---\nkind: Deployment\nspec:\n  replicas: 2\n",
+			sourcePath: "spec.replicas",
+			target:     "# service\nkind: Service\n---\n# deployment\nkind: Deployment\nspec:\n  replicas: 1\n",
+			targetPath: "spec.replicas",
+			want:       "# service\nkind: Service\n---\n# deployment\nkind: Deployment\nspec:\n  replicas: 2\n",
+		},
+		{
+			name:       "flow mapping",
+			source:     "tag: 1.1.0\n",
+			sourcePath: "tag",
+			target:     "image: {repository: app, tag: 1.0.0} # image\n",
+			targetPath: "image.tag",
+			want:       "image: {repository: app, tag: 1.1.0} # image\n",
+		},
+		{
+			name:       "flow sequence",
+			source:     "tag: 1.1.0\n",
+			sourcePath: "tag",
+			target:     "tags: [latest, \"1.0.0\"] # tags\n",
+			targetPath: "tags[1]",
+			want:       "tags: [latest, 1.1.0] # tags\n",
+		},
+		{
+			name:       "nested flow mapping",
+			source:     "tag: 1.1.0\n",
+			sourcePath: "tag",
+			target:     "# images\nimages: {app: {repository: app, tag: '1.0.0'}}\n",
+			targetPath: "images.app.tag",
+			want:       "# images\nimages: {app: {repository: app, tag: 1.1.0}}\n",
+		},
+		{
+			name:       "block value replaces flow mapping",
+			source:     "image:\n  repository: app\n  tag: 1.1.0\n",
+			sourcePath: "image",
+			target:     "# the image\nimage: {repository: app} # old\nreplicas: 1\n",
+			targetPath: "image",
+			want:       "# the image\nimage:\n  repository: app\n  tag: 1.1.0 # old\nreplicas: 1\n",
+		},
+		{
+			name:       "empty target",
+			source:     "tag: 1.1.0\n",
+			sourcePath: "tag",
+			targetPath: "image.tag",
+			want:       "image:\n  tag: 1.1.0\n",
+		},
+		{
+			name:       "aliases are resolved",
+			source:     "default: &default 1.1.0\nimage:\n  tag: *default\n",
+			sourcePath: "image.tag",
+			target:     "image:\n  tag: 1.0.0\n",
+			targetPath: "image.tag",
+			want:       "image:\n  tag: 1.1.0\n",
+		},
+		{
+			name:       "append to sequence",
+			source:     "- name: sidecar # new\n  image: sidecar:1\n",
+			sourcePath: "[0]",
+			target:     "containers:\n- name: app\n  image: app:1\nreplicas: 1\n",
+			targetPath: "containers[1]",
+			want:       "containers:\n- name: app\n  image: app:1\n- name: sidecar # new\n  image: sidecar:1\nreplicas: 1\n",
+		},
+		{
+			name:       "anchors are kept",
+			source:     "tag: 1.1.0\n",
+			sourcePath: "tag",
+			target:     "tag: &tag 1.0.0\nother: *tag\n",
+			targetPath: "tag",
+			want:       "tag: &tag 1.1.0\nother: *tag\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := Copy([]byte(tt.source), tt.sourcePath, []byte(tt.target), tt.targetPath)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(string(got)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestCopy_Errors(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := Copy([]byte("a: 1\n"), "b", []byte("a: 2\n"), "a")
+	g.Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+
+	_, err = Copy([]byte("a: 1\n"), "a", []byte("a: [1]\n"), "a[2]")
+	g.Expect(err).To(MatchError("cannot set a[2]: index 2 out of range"))
+
+	_, err = Copy([]byte("a: 1\n"), "a", []byte("a: 2\n"), "a.b")
+	g.Expect(err).To(MatchError("cannot set a.b: a is not a mapping"))
+
+	_, err = Copy([]byte("a: 1\n"), "a", []byte("a: :\n  -"), "a")
+	g.Expect(err).To(HaveOccurred())
+
+	// Flow collections are not reformatted to add keys or items.
+	for _, target := range []string{"image: {repository: app} # image\n", "image: {}\n"} {
+		_, err = Copy([]byte("tag: 1.1.0\n"), "tag", []byte(target), "image.tag")
+		g.Expect(errors.Is(err, ErrUnsupported)).To(BeTrue(), target)
+	}
+	_, err = Copy([]byte("tag: 1.1.0\n"), "tag", []byte("tags: [1.0.0]\n"), "tags[1]")
+	g.Expect(errors.Is(err, ErrUnsupported)).To(BeTrue())
+}
+
+func TestGetSet(t *testing.T) {
+	g := NewWithT(t)
+
+	node, err := Get([]byte(deployment), "spec.replicas")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(node.Value).To(Equal("3"))
+
+	_, err = Get([]byte(deployment), "spec.template.spec.containers[1]")
+	g.Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+
+	got, err := Set([]byte("a: 1 # one\n"), "a", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "2"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(got)).To(Equal("a: \"2\" # one\n"))
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []segment
+		wantErr bool
+	}{
+		{path: "a.b", want: []segment{{key: "a", index: -1}, {key: "b", index: -1}}},
+		{path: "$.a[0].b", want: []segment{{key: "a", index: -1}, {index: 0}, {key: "b", index: -1}}},
+		{path: `.a["b.c"][1]`, want: []segment{{key: "a", index: -1}, {key: "b.c", index: -1}, {index: 1}}},
+		{path: "[2]", want: []segment{{index: 2}}},
+		{path: "", wantErr: true},
+		{path: "a..b", wantErr: true},
+		{path: "a.", wantErr: true},
+		{path: "a[x]", wantErr: true},
+		{path: "a[-1]", wantErr: true},
+		{path: "a[0", wantErr: true},
+		{path: "a[0]b", wantErr: true},
+		{path: `a["b]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := parse(tt.path)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+			g.Expect(parse(format(got))).To(Equal(tt.want))
+		})
+	}
+}